@@ -2,6 +2,11 @@ module test
 
 go 1.18
 
-replace wf => ./wf
+replace (
+	dc => ../distributed_cache/dc
+	wf => ./wf
+)
 
 require wf v0.0.0-00010101000000-000000000000
+
+require dc v0.0.0-00010101000000-000000000000 // indirect
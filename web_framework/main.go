@@ -2,24 +2,11 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 	"wf"
 )
 
-func Logger() wf.HandlerFunc {
-	return func(c *wf.Context) {
-		// Start timer
-		t := time.Now()
-		log.Printf("time: %v", t)
-		// Process request
-		c.Next()
-		// Calculate resolution time
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Request.RequestURI, time.Since(t))
-	}
-}
-
 type student struct {
 	Name string
 	Age  int8
@@ -31,7 +18,7 @@ func FormatAsDate(t time.Time) string {
 }
 
 func main() {
-	r := wf.New().Use(wf.Recovery())
+	r := wf.New().Use(wf.Logger(), wf.Recovery())
 	r.GET("/", func(ctx *wf.Context) {
 		ctx.String(http.StatusOK, FormatAsDate(time.Now()))
 	})
@@ -0,0 +1,146 @@
+package wf
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AuthUserKey 为认证中间件通过 c.Set 保存当前用户的键
+const AuthUserKey = "wf/user"
+
+// Accounts 为 BasicAuth 的用户名到密码的映射
+type Accounts map[string]string
+
+// BasicAuth 返回 HTTP Basic 认证中间件, realm 为 "Authorization Required"
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuthForRealm 校验 Authorization 头中的用户名和密码, 成功时将用户名保存到 AuthUserKey
+// 比较时遍历所有账号并使用常数时间比较, 响应时间不会泄漏用户名或密码
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	if len(accounts) == 0 {
+		panic("Empty list of authorized accounts")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	type account struct {
+		user, password [sha256.Size]byte
+		name           string
+	}
+	list := make([]account, 0, len(accounts))
+	for user, password := range accounts {
+		if user == "" {
+			panic("User can not be empty")
+		}
+		list = append(list, account{user: sha256.Sum256([]byte(user)), password: sha256.Sum256([]byte(password)), name: user})
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(c *Context) {
+		user, password, ok := c.Request.BasicAuth()
+		found := ""
+		if ok {
+			userSum, passwordSum := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(password))
+			for _, a := range list {
+				match := subtle.ConstantTimeCompare(userSum[:], a.user[:]) & subtle.ConstantTimeCompare(passwordSum[:], a.password[:])
+				if match == 1 {
+					found = a.name
+				}
+			}
+		}
+		if found == "" {
+			c.Writer.Header().Set("WWW-Authenticate", challenge)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(AuthUserKey, found)
+		c.Next()
+	}
+}
+
+// KeyLookupFunc 返回 key 对应的用户, ok 为 false 时拒绝请求
+type KeyLookupFunc func(c *Context, key string) (user interface{}, ok bool)
+
+// KeyAuthConfig 配置 API key 认证
+// Header 和 Query 都为空时从 "Authorization: Bearer <key>" 中读取
+type KeyAuthConfig struct {
+	Lookup KeyLookupFunc
+	// Header 为携带 key 的请求头
+	Header string
+	// Scheme 为请求头中 key 之前的认证方案, 例如 Bearer
+	Scheme string
+	// Query 为携带 key 的查询参数, 请求头中没有 key 时使用
+	Query string
+}
+
+// KeyAuth 返回从 "Authorization: Bearer <key>" 读取 key 的认证中间件
+func KeyAuth(lookup KeyLookupFunc) HandlerFunc {
+	return KeyAuthWithConfig(KeyAuthConfig{Lookup: lookup})
+}
+
+// KeyAuthWithConfig 返回 API key 认证中间件, 成功时将 Lookup 返回的用户保存到 AuthUserKey
+func KeyAuthWithConfig(conf KeyAuthConfig) HandlerFunc {
+	if conf.Lookup == nil {
+		panic("KeyAuth requires a lookup function")
+	}
+	src := newTokenSource(conf.Header, conf.Scheme, conf.Query)
+	return func(c *Context) {
+		key := src.extract(c)
+		if key != "" {
+			if user, ok := conf.Lookup(c, key); ok {
+				c.Set(AuthUserKey, user)
+				c.Next()
+				return
+			}
+		}
+		src.challenge(c, "")
+	}
+}
+
+// tokenSource 描述从请求头或查询参数中读取凭据的位置, 供 KeyAuth 和 JWT 共用
+type tokenSource struct {
+	header, scheme, query string
+}
+
+func newTokenSource(header, scheme, query string) tokenSource {
+	if header == "" && query == "" {
+		header, scheme = "Authorization", "Bearer"
+	}
+	return tokenSource{header: header, scheme: scheme, query: query}
+}
+
+func (s tokenSource) extract(c *Context) string {
+	if s.header != "" {
+		if v := c.GetHeader(s.header); v != "" {
+			if s.scheme == "" {
+				return v
+			}
+			// 认证方案不区分大小写
+			if len(v) > len(s.scheme) && strings.EqualFold(v[:len(s.scheme)], s.scheme) && v[len(s.scheme)] == ' ' {
+				return strings.TrimSpace(v[len(s.scheme)+1:])
+			}
+			return ""
+		}
+	}
+	if s.query != "" {
+		return c.Query(s.query)
+	}
+	return ""
+}
+
+// challenge 返回 401, 使用认证方案时带上 WWW-Authenticate, errCode 为 RFC 6750 的错误码
+func (s tokenSource) challenge(c *Context, errCode string) {
+	if s.scheme != "" {
+		value := s.scheme
+		if errCode != "" {
+			value += ` error="` + errCode + `"`
+		}
+		c.Writer.Header().Set("WWW-Authenticate", value)
+	}
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
@@ -0,0 +1,81 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func basicAuthHeader(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func TestBasicAuth(t *testing.T) {
+	r := New()
+	r.Use(BasicAuth(Accounts{"geek": "secret", "admin": "admin"}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, c.GetString(AuthUserKey)) })
+
+	w := performRequest(r, "GET", "/", withHeader("Authorization", basicAuthHeader("geek", "secret")))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "geek", w.Body.String())
+
+	for _, auth := range []string{"", basicAuthHeader("geek", "admin"), basicAuthHeader("nobody", "secret"), "Bearer x"} {
+		w = performRequest(r, "GET", "/", withHeader("Authorization", auth))
+		require.Equal(t, http.StatusUnauthorized, w.Code, auth)
+		require.Equal(t, `Basic realm="Authorization Required", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+	}
+
+	r = New()
+	r.Use(BasicAuthForRealm(Accounts{"geek": "secret"}, `Admin "area"`))
+	r.GET("/", func(c *Context) {})
+	w = performRequest(r, "GET", "/")
+	require.Equal(t, `Basic realm="Admin \"area\"", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+
+	require.Panics(t, func() { BasicAuth(Accounts{}) })
+	require.Panics(t, func() { BasicAuth(Accounts{"": "x"}) })
+}
+
+func TestKeyAuth(t *testing.T) {
+	lookup := func(c *Context, key string) (interface{}, bool) {
+		if key == "valid-key" {
+			return "service-a", true
+		}
+		return nil, false
+	}
+	r := New()
+	r.GET("/bearer", KeyAuth(lookup), func(c *Context) { c.String(http.StatusOK, c.GetString(AuthUserKey)) })
+	r.GET("/custom", KeyAuthWithConfig(KeyAuthConfig{Lookup: lookup, Header: "X-API-Key", Query: "api_key"}),
+		func(c *Context) { c.String(http.StatusOK, c.GetString(AuthUserKey)) })
+
+	w := performRequest(r, "GET", "/bearer", withHeader("Authorization", "bearer valid-key"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "service-a", w.Body.String())
+
+	for _, auth := range []string{"", "Bearer wrong", "Bearervalid-key", "Basic valid-key"} {
+		w = performRequest(r, "GET", "/bearer", withHeader("Authorization", auth))
+		require.Equal(t, http.StatusUnauthorized, w.Code, auth)
+		require.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	}
+
+	w = performRequest(r, "GET", "/custom", withHeader("X-API-Key", "valid-key"))
+	require.Equal(t, "service-a", w.Body.String())
+	require.Equal(t, "service-a", performRequest(r, "GET", "/custom?api_key=valid-key").Body.String())
+	w = performRequest(r, "GET", "/custom?api_key=wrong")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Empty(t, w.Header().Get("WWW-Authenticate"))
+
+	require.Panics(t, func() { KeyAuth(nil) })
+}
+
+func TestLoggerAuthUser(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{Output: buf, Format: LogFormatCommon}), BasicAuth(Accounts{"geek": "secret"}))
+	r.GET("/", func(c *Context) {})
+	performRequest(r, "GET", "/", withHeader("Authorization", basicAuthHeader("geek", "secret")))
+	require.True(t, strings.Contains(buf.String(), " - geek ["), buf.String())
+}
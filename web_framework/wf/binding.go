@@ -0,0 +1,138 @@
+package wf
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultMultipartMemory = 32 << 20 // 32 MB
+
+// Binding 从请求中解析数据到结构体
+type Binding interface {
+	Name() string
+	Bind(*http.Request, interface{}) error
+}
+
+var (
+	BindingJSON   Binding = jsonBinding{}
+	BindingXML    Binding = xmlBinding{}
+	BindingForm   Binding = formBinding{}
+	BindingQuery  Binding = queryBinding{}
+	BindingHeader Binding = headerBinding{}
+)
+
+// BindError 记录绑定失败的来源(json/xml/form/query/header/uri)和字段
+type BindError struct {
+	Source string
+	Field  string
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("bind %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("bind %s: field %s: %v", e.Source, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// defaultBinding 根据请求方法和 Content-Type 选择 Binding
+func defaultBinding(method, contentType string) Binding {
+	if method == http.MethodGet || method == http.MethodHead {
+		return BindingForm
+	}
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		return BindingJSON
+	case contentType == "application/xml" || contentType == "text/xml" || strings.HasSuffix(contentType, "+xml"):
+		return BindingXML
+	default:
+		return BindingForm
+	}
+}
+
+type jsonBinding struct{}
+
+func (jsonBinding) Name() string {
+	return "json"
+}
+
+func (jsonBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return &BindError{Source: "json", Err: errors.New("empty request body")}
+	}
+	err := json.NewDecoder(req.Body).Decode(obj)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &BindError{Source: "json", Field: typeErr.Field, Err: err}
+		}
+		return &BindError{Source: "json", Err: err}
+	}
+	return nil
+}
+
+type xmlBinding struct{}
+
+func (xmlBinding) Name() string {
+	return "xml"
+}
+
+func (xmlBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return &BindError{Source: "xml", Err: errors.New("empty request body")}
+	}
+	if err := xml.NewDecoder(req.Body).Decode(obj); err != nil {
+		return &BindError{Source: "xml", Err: err}
+	}
+	return nil
+}
+
+type formBinding struct{}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+// Bind 使用 req.Form, 同时包含 query 和 urlencoded/multipart 表单
+func (formBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return &BindError{Source: "form", Err: err}
+	}
+	return mapping(obj, formSource(req.Form), "form", "form")
+}
+
+type queryBinding struct{}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+func (queryBinding) Bind(req *http.Request, obj interface{}) error {
+	return mapping(obj, formSource(req.URL.Query()), "form", "query")
+}
+
+type headerBinding struct{}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+func (headerBinding) Bind(req *http.Request, obj interface{}) error {
+	return mapping(obj, headerSource(req.Header), "header", "header")
+}
+
+func bindURI(params Params, obj interface{}) error {
+	values := make(formSource, len(params))
+	for _, p := range params {
+		values[p.Key] = []string{p.Value}
+	}
+	return mapping(obj, values, "uri", "uri")
+}
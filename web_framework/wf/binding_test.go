@@ -0,0 +1,162 @@
+package wf
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type bindAddress struct {
+	City string `form:"city" json:"city" xml:"city"`
+	Zip  int    `form:"zip" json:"zip" xml:"zip"`
+}
+
+type bindUser struct {
+	Name     string      `form:"name" json:"name" xml:"name"`
+	Age      int         `form:"age,default=18" json:"age" xml:"age"`
+	Tags     []string    `form:"tag" json:"tags" xml:"tag"`
+	Score    *float64    `form:"score" json:"score" xml:"score"`
+	Birthday time.Time   `form:"birthday" time_format:"2006-01-02" json:"-" xml:"-"`
+	Address  bindAddress `json:"address" xml:"address"`
+	Extra    *bindExtra  `json:"-" xml:"-"`
+	Ignored  string      `form:"-" json:"-" xml:"-"`
+}
+
+type bindExtra struct {
+	Note string `form:"note"`
+}
+
+func TestBindQuery(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?name=geek&tag=a&tag=b&score=9.5&birthday=2000-01-02&city=sh&zip=200&Ignored=x", nil)
+	var u bindUser
+	require.NoError(t, BindingQuery.Bind(req, &u))
+	require.Equal(t, "geek", u.Name)
+	require.Equal(t, 18, u.Age)
+	require.Equal(t, []string{"a", "b"}, u.Tags)
+	require.Equal(t, 9.5, *u.Score)
+	require.Equal(t, 2000, u.Birthday.Year())
+	require.Equal(t, time.January, u.Birthday.Month())
+	require.Equal(t, bindAddress{City: "sh", Zip: 200}, u.Address)
+	require.Nil(t, u.Extra)
+	require.Empty(t, u.Ignored)
+
+	req = httptest.NewRequest("GET", "/?note=hi", nil)
+	u = bindUser{}
+	require.NoError(t, BindingQuery.Bind(req, &u))
+	require.Equal(t, "hi", u.Extra.Note)
+	require.Nil(t, u.Score)
+}
+
+func TestBindError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?zip=abc", nil)
+	var u bindUser
+	err := BindingQuery.Bind(req, &u)
+	var bindErr *BindError
+	require.True(t, errors.As(err, &bindErr))
+	require.Equal(t, "query", bindErr.Source)
+	require.Equal(t, "Address.Zip", bindErr.Field)
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"address":{"zip":"abc"}}`))
+	err = BindingJSON.Bind(req, &u)
+	require.True(t, errors.As(err, &bindErr))
+	require.Equal(t, "json", bindErr.Source)
+	require.Equal(t, "address.zip", bindErr.Field)
+
+	require.Error(t, BindingQuery.Bind(req, u))
+}
+
+type bindCategory struct {
+	Name   string `form:"name"`
+	Parent *bindCategory
+}
+
+func TestBindRecursiveType(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?name=go", nil)
+	var c bindCategory
+	require.NoError(t, BindingQuery.Bind(req, &c))
+	require.Equal(t, "go", c.Name)
+	require.Nil(t, c.Parent)
+}
+
+func TestBindHeader(t *testing.T) {
+	var h struct {
+		RequestID string        `header:"x-request-id"`
+		Limit     int           `header:"X-Limit,default=10"`
+		Timeout   time.Duration `header:"X-Timeout"`
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("X-Timeout", "3s")
+	require.NoError(t, BindingHeader.Bind(req, &h))
+	require.Equal(t, "abc", h.RequestID)
+	require.Equal(t, 10, h.Limit)
+	require.Equal(t, 3*time.Second, h.Timeout)
+}
+
+func TestContextBind(t *testing.T) {
+	r := New()
+	var got bindUser
+	r.POST("/users", func(c *Context) {
+		got = bindUser{}
+		if c.Bind(&got) == nil {
+			c.String(http.StatusOK, "ok")
+		}
+	})
+	r.GET("/users/:id/:name", func(c *Context) {
+		var uri struct {
+			ID   int    `uri:"id"`
+			Name string `uri:"name"`
+		}
+		if c.BindURI(&uri) == nil {
+			c.JSON(http.StatusOK, uri)
+		}
+	})
+
+	send := func(contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/users", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("application/json; charset=utf-8", bytes.NewBufferString(`{"name":"geek","age":20,"address":{"city":"sh"}}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "geek", got.Name)
+	require.Equal(t, "sh", got.Address.City)
+
+	w = send("application/xml", bytes.NewBufferString(`<user><name>geek</name><tag>a</tag><tag>b</tag></user>`))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"a", "b"}, got.Tags)
+
+	w = send("application/x-www-form-urlencoded", bytes.NewBufferString("name=geek&age=30"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 30, got.Age)
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	require.NoError(t, mw.WriteField("name", "multi"))
+	require.NoError(t, mw.Close())
+	w = send(mw.FormDataContentType(), body)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "multi", got.Name)
+
+	w = send("application/json", bytes.NewBufferString(`{"age":"x"}`))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "bind json")
+
+	w = performRequest(r, "GET", "/users/7/geek")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"ID":7,"Name":"geek"}`, w.Body.String())
+
+	w = performRequest(r, "GET", "/users/x/geek")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "field ID")
+}
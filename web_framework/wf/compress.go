@@ -0,0 +1,289 @@
+package wf
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressConfig 配置响应压缩
+type CompressConfig struct {
+	// Level 为压缩级别, 取值同 compress/flate, 为 nil 时使用 flate.DefaultCompression
+	Level *int
+	// MinLength 为开始压缩的最小 body 长度, 默认为 1024, 负数表示总是压缩
+	MinLength int
+	// ExcludedContentTypes 为额外不压缩的类型, 以 "/" 结尾时按前缀匹配, 例如 "image/"
+	// 图片、音视频和常见的压缩格式默认不压缩
+	ExcludedContentTypes []string
+	// ExcludedPaths 为不压缩的请求路径前缀
+	ExcludedPaths []string
+}
+
+const defaultCompressMinLength = 1024
+
+// defaultExcludedContentTypes 为已经压缩过的类型, 再次压缩只会浪费 CPU
+var defaultExcludedContentTypes = []string{
+	"image/", "audio/", "video/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/wasm",
+}
+
+// compressibleImages 为 image/ 下基于文本的格式
+var compressibleImages = map[string]bool{"image/svg+xml": true, "image/bmp": true, "image/x-icon": true}
+
+// Compress 根据 Accept-Encoding 选择 gzip 或 deflate 压缩响应
+// body 先缓冲到 MinLength 再决定是否压缩, 调用 Flush 时立即开始压缩, 所以 Stream 和 SSE 也会被压缩
+func Compress(conf CompressConfig) HandlerFunc {
+	level := flate.DefaultCompression
+	if conf.Level != nil {
+		level = *conf.Level
+	}
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		panic("Invalid compression level: " + strconv.Itoa(level))
+	}
+	if conf.MinLength == 0 {
+		conf.MinLength = defaultCompressMinLength
+	}
+	excluded := append(append([]string(nil), defaultExcludedContentTypes...), conf.ExcludedContentTypes...)
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+		"deflate": {New: func() interface{} {
+			w, _ := flate.NewWriter(io.Discard, level)
+			return w
+		}},
+	}
+
+	return func(c *Context) {
+		for _, prefix := range conf.ExcludedPaths {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		addVary(c.Writer.Header(), "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			minLength:      conf.MinLength,
+			excluded:       excluded,
+		}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			w.release()
+		}()
+		c.Next()
+		w.close()
+	}
+}
+
+// negotiateEncoding 返回 q 值最高的 gzip 或 deflate, 相同时优先 gzip, 都不接受时返回空字符串
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		value := 1.0
+		for _, param := range params[1:] {
+			key, v, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(key), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					value = f
+				}
+			}
+		}
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		q[coding] = value
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		v, ok := q[coding]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > bestQ {
+			best, bestQ = coding, v
+		}
+	}
+	return best
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter 在确定是否压缩之前缓冲 body, 确定之后直接写入压缩器或底层 ResponseWriter
+type compressWriter struct {
+	ResponseWriter
+	encoding  string
+	pool      *sync.Pool
+	minLength int
+	excluded  []string
+
+	buf     []byte
+	size    int
+	decided bool
+	cw      compressor
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.size += len(data)
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.minLength {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 在缓冲的 body 达到 MinLength 之前调用时不压缩, 例如 AbortWithStatus
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Size 返回压缩前写入的字节数
+func (w *compressWriter) Size() int {
+	if w.size == 0 && !w.ResponseWriter.Written() {
+		return noWritten
+	}
+	return w.size
+}
+
+// Flush 用于流式响应, 此时不再等待 MinLength
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide 根据状态码和响应头确定是否压缩, 并写出已缓冲的数据
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compress && header.Get("Content-Type") == "" {
+		// 先按原始数据探测类型, 否则 net/http 会对压缩后的数据探测
+		if len(w.buf) == 0 {
+			compress = false
+		} else {
+			header.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+	}
+	if compress && w.compressible() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// 压缩后的表示与原来不是逐字节相同, 强 ETag 改为弱 ETag
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.cw = w.pool.Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	status := w.Status()
+	header := w.Header()
+	if !bodyAllowedForStatus(status) || status == http.StatusPartialContent ||
+		header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return true
+	}
+	if compressibleImages[mediaType] {
+		return true
+	}
+	for _, t := range w.excluded {
+		if mediaType == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// close 在处理结束时写出剩余的数据, 此时仍在缓冲的 body 不足 MinLength
+func (w *compressWriter) close() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
+
+// release 将压缩器放回池中, panic 时未关闭的压缩器在下次 Reset 时丢弃
+func (w *compressWriter) release() {
+	if w.cw != nil {
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
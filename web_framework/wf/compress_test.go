@@ -0,0 +1,136 @@
+package wf
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func gunzip(t *testing.T, body io.Reader) string {
+	zr, err := gzip.NewReader(body)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                         "",
+		"gzip, deflate, br":        "gzip",
+		"deflate":                  "deflate",
+		"gzip;q=0.5, deflate":      "deflate",
+		"gzip;q=0, *":              "deflate",
+		"*":                        "gzip",
+		"identity":                 "",
+		"br, GZIP;q=0.1":           "gzip",
+		"x-gzip":                   "gzip",
+		"gzip;q=0, deflate;q=0, *": "",
+	} {
+		require.Equal(t, expected, negotiateEncoding(header), header)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("wf compress ", 200)
+	r := New()
+	r.Use(Compress(CompressConfig{ExcludedPaths: []string{"/raw"}}))
+	r.GET("/large", func(c *Context) {
+		c.Writer.Header().Set("Content-Length", "2400")
+		c.Writer.Header().Set("ETag", `"v1"`)
+		c.String(http.StatusOK, large)
+	})
+	r.GET("/small", func(c *Context) { c.String(http.StatusOK, "small") })
+	r.GET("/png", func(c *Context) { c.Render(http.StatusOK, DataRender{ContentType: "image/png", Data: []byte(large)}) })
+	r.GET("/svg", func(c *Context) {
+		c.Render(http.StatusOK, DataRender{ContentType: "image/svg+xml", Data: []byte(large)})
+	})
+	r.GET("/raw", func(c *Context) { c.String(http.StatusOK, large) })
+	r.GET("/empty", func(c *Context) { c.AbortWithStatus(http.StatusNoContent) })
+
+	w := performRequest(r, "GET", "/large", withHeader("Accept-Encoding", "gzip, deflate"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Empty(t, w.Header().Get("Content-Length"))
+	require.Equal(t, `W/"v1"`, w.Header().Get("ETag"))
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Less(t, w.Body.Len(), len(large))
+	require.Equal(t, large, gunzip(t, w.Body))
+
+	w = performRequest(r, "GET", "/large", withHeader("Accept-Encoding", "deflate"))
+	require.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	data, err := io.ReadAll(flate.NewReader(w.Body))
+	require.NoError(t, err)
+	require.Equal(t, large, string(data))
+
+	// 压缩器放回池中后可以复用
+	for i := 0; i < 3; i++ {
+		w = performRequest(r, "GET", "/large", withHeader("Accept-Encoding", "gzip"))
+		require.Equal(t, large, gunzip(t, w.Body))
+	}
+
+	w = performRequest(r, "GET", "/large", withHeader("Accept-Encoding", "br"))
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Equal(t, "2400", w.Header().Get("Content-Length"))
+	require.Equal(t, large, w.Body.String())
+
+	for path, expected := range map[string]string{"/small": "small", "/png": large, "/raw": large} {
+		w = performRequest(r, "GET", path, withHeader("Accept-Encoding", "gzip"))
+		require.Equal(t, http.StatusOK, w.Code, path)
+		require.Empty(t, w.Header().Get("Content-Encoding"), path)
+		require.Equal(t, expected, w.Body.String(), path)
+	}
+	require.Empty(t, performRequest(r, "GET", "/raw", withHeader("Accept-Encoding", "gzip")).Header().Get("Vary"))
+
+	w = performRequest(r, "GET", "/svg", withHeader("Accept-Encoding", "gzip"))
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, large, gunzip(t, w.Body))
+
+	w = performRequest(r, "GET", "/empty", withHeader("Accept-Encoding", "gzip"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Body.String())
+
+	// NoCompression 只加上 gzip 格式, 不压缩数据
+	noCompression := gzip.NoCompression
+	r = New()
+	r.Use(Compress(CompressConfig{Level: &noCompression}))
+	r.GET("/large", func(c *Context) { c.String(http.StatusOK, large) })
+	w = performRequest(r, "GET", "/large", withHeader("Accept-Encoding", "gzip"))
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Greater(t, w.Body.Len(), len(large))
+	require.Equal(t, large, gunzip(t, w.Body))
+
+	invalid := 10
+	require.Panics(t, func() { Compress(CompressConfig{Level: &invalid}) })
+}
+
+func TestCompressStream(t *testing.T) {
+	r := New()
+	r.Use(Compress(CompressConfig{}))
+	var flushed []int
+	r.GET("/events", func(c *Context) {
+		for i := 0; i < 3; i++ {
+			c.SSE(ServerSentEvent{Event: "tick", Data: i})
+			flushed = append(flushed, c.Writer.(*compressWriter).ResponseWriter.Size())
+		}
+	})
+
+	w := performRequest(r, "GET", "/events", withHeader("Accept-Encoding", "gzip"))
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	require.True(t, w.Flushed)
+	// 每个事件在 Flush 时都已经写到底层连接
+	require.Len(t, flushed, 3)
+	require.Greater(t, flushed[0], 0)
+	require.Greater(t, flushed[1], flushed[0])
+	require.Greater(t, flushed[2], flushed[1])
+	require.Equal(t, "event: tick\ndata: 0\n\nevent: tick\ndata: 1\n\nevent: tick\ndata: 2\n\n", gunzip(t, w.Body))
+}
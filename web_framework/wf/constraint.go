@@ -0,0 +1,38 @@
+package wf
+
+import (
+	"regexp"
+	"strings"
+)
+
+// paramConstraints 为内置的参数类型, 其他约束按正则表达式处理
+var paramConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"float": `-?[0-9]+(?:\.[0-9]+)?`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// splitConstraint eg: id<int> -> id, int
+func splitConstraint(text string) (name, constraint string) {
+	i := strings.IndexByte(text, '<')
+	if i < 0 || text[len(text)-1] != '>' {
+		return text, ""
+	}
+	return text[:i], text[i+1 : len(text)-1]
+}
+
+// compileConstraint 返回匹配整个参数值的函数, 正则表达式不能包含 '/'
+func compileConstraint(constraint string) func(string) bool {
+	expr, ok := paramConstraints[constraint]
+	if !ok {
+		expr = constraint
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic("Invalid param constraint: " + constraint)
+	}
+	return re.MatchString
+}
@@ -0,0 +1,90 @@
+package wf
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstrainedParams(t *testing.T) {
+	r := New()
+	r.addRoute("GET", "/users/:name", nil)
+	r.addRoute("GET", "/users/:id<int>", nil)
+	r.addRoute("GET", "/users/me", nil)
+	r.addRoute("GET", "/users/*rest", nil)
+	r.addRoute("GET", "/files/:name<[a-z]+\\.txt>", nil)
+	r.addRoute("GET", "/files/:name<[a-z]+\\.md>", nil)
+	r.addRoute("GET", "/v/:uuid<uuid>", nil)
+	r.addRoute("GET", "/v/:id<int>/x", nil)
+
+	tests := []struct {
+		path  string
+		route string
+		value string
+	}{
+		{"/users/me", "/users/me", ""},
+		{"/users/42", "/users/:id<int>", "42"},
+		{"/users/-7", "/users/:id<int>", "-7"},
+		{"/users/geek", "/users/:name", "geek"},
+		{"/users/a/b", "/users/*rest", "a/b"},
+		{"/files/readme.txt", "/files/:name<[a-z]+\\.txt>", "readme.txt"},
+		{"/files/readme.md", "/files/:name<[a-z]+\\.md>", "readme.md"},
+		{"/files/README.txt", "", ""},
+		{"/v/123e4567-e89b-12d3-a456-426614174000", "/v/:uuid<uuid>", "123e4567-e89b-12d3-a456-426614174000"},
+		{"/v/12/x", "/v/:id<int>/x", "12"},
+		{"/v/abc", "", ""},
+	}
+	for _, test := range tests {
+		n, params := r.getRoute("GET", test.path)
+		if test.route == "" {
+			require.Nil(t, n, test.path)
+			continue
+		}
+		require.NotNil(t, n, test.path)
+		require.Equal(t, test.route, n.path, test.path)
+		if test.value != "" {
+			require.Equal(t, test.value, params[0].Value, test.path)
+		}
+	}
+}
+
+func TestConstraintConflict(t *testing.T) {
+	r := New()
+	r.addRoute("GET", "/a/:id<int>", nil)
+	r.addRoute("GET", "/a/:name", nil)
+	r.addRoute("GET", "/a/:slug<[a-z]+>", nil)
+	require.Panics(t, func() { r.addRoute("GET", "/a/:num<int>", nil) })
+	require.Panics(t, func() { r.addRoute("GET", "/a/:other", nil) })
+
+	// 带约束的节点优先于无约束的节点, 与注册顺序无关
+	wild := r.roots["GET"].children[0].wildChildren
+	require.Equal(t, "int", wild[0].constraint)
+	require.Equal(t, "[a-z]+", wild[1].constraint)
+	require.Equal(t, "", wild[2].constraint)
+
+	require.Panics(t, func() { r.addRoute("GET", "/b/:id<[a-z>", nil) })
+	n, _ := r.getRoute("GET", "/b")
+	require.Nil(t, n)
+	require.Equal(t, "/a/", r.roots["GET"].children[0].prefix)
+}
+
+func TestParamInt(t *testing.T) {
+	r := New()
+	r.GET("/users/:id<int>", func(c *Context) {
+		id, err := c.ParamInt("id")
+		require.NoError(t, err)
+		c.JSON(http.StatusOK, H{"id": id})
+	}).Name("user")
+
+	w := performRequest(r, "GET", "/users/42")
+	require.JSONEq(t, `{"id":42}`, w.Body.String())
+	w = performRequest(r, "GET", "/users/abc")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	url, err := r.URL("user", "id", "7")
+	require.NoError(t, err)
+	require.Equal(t, "/users/7", url)
+	_, err = r.URL("user", "id", "x")
+	require.Error(t, err)
+}
@@ -1,36 +1,93 @@
 package wf
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type H map[string]interface{}
 
+// abortIndex 大于任何中间件链的长度, Next 遇到后直接返回
+const abortIndex = math.MaxInt16
+
 type Context struct {
-	Writer     http.ResponseWriter
-	Request    *http.Request
-	Path       string
-	Method     string
-	Params     map[string]string
+	Writer  ResponseWriter
+	Request *http.Request
+	Path    string
+	Method  string
+	Params  Params
+	// StatusCode 只在调用 Status 时设置, 实际的响应状态码使用 Writer.Status()
 	StatusCode int
-	engine     *Engine
-	handlers   HandlersChain
-	index      int
+	// Errors 收集处理过程中通过 Error 添加的错误, 供日志等中间件在 Next 之后使用
+	Errors Errors
+	// Keys 为请求范围内的键值对, 通过 Set/Get 并发安全地访问
+	Keys      map[string]interface{}
+	mu        sync.RWMutex
+	engine    *Engine
+	writermem responseWriter
+	handlers  HandlersChain
+	index     int
 }
 
-func newContext(w http.ResponseWriter, r *http.Request, e *Engine) *Context {
+func newContext(e *Engine) *Context {
 	return &Context{
-		Writer:  w,
-		Request: r,
-		Path:    r.URL.Path,
-		Method:  r.Method,
-		engine:  e,
-		index:   -1,
+		Params:    make(Params, 0, e.maxParams),
+		engine:    e,
+		writermem: responseWriter{engine: e},
+		index:     -1,
 	}
 }
 
+// reset 在从池中取出后重置 Context, 防止上一个请求的状态泄漏
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Request = r
+	c.Path = r.URL.Path
+	c.Method = r.Method
+	c.Params = c.Params[:0]
+	c.StatusCode = 0
+	c.Errors = c.Errors[:0]
+	c.Keys = nil
+	c.handlers = nil
+	c.index = -1
+}
+
+// Copy 返回当前 Context 的快照, 需要在 goroutine 中使用 Context 时应传递副本
+// 副本不能写响应, 原 Context 在请求结束后会被复用
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Request:    c.Request,
+		Path:       c.Path,
+		Method:     c.Method,
+		Params:     make(Params, len(c.Params)),
+		StatusCode: c.StatusCode,
+		Errors:     make(Errors, len(c.Errors)),
+		engine:     c.engine,
+		index:      abortIndex,
+	}
+	copy(cp.Params, c.Params)
+	copy(cp.Errors, c.Errors)
+
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
+}
+
 func (c *Context) Next() {
 	c.index++
 	for c.index < len(c.handlers) {
@@ -39,7 +96,178 @@ func (c *Context) Next() {
 	}
 }
 
+// Abort 阻止执行后续的处理函数, 已经执行的中间件在 Next 返回后继续执行
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
+
+// Error 将 err 添加到 c.Errors, 默认类型为 ErrorTypePrivate
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("err is nil")
+	}
+
+	var parsedError *Error
+	if !errors.As(err, &parsedError) {
+		parsedError = &Error{Err: err, Type: ErrorTypePrivate}
+	}
+	c.Errors = append(c.Errors, parsedError)
+	return parsedError
+}
+
+// Set 保存请求范围内的值, 如中间件解析出的用户或请求 ID
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+	c.mu.Unlock()
+}
+
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	value, exists = c.Keys[key]
+	c.mu.RUnlock()
+	return
+}
+
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("Key \"" + key + "\" does not exist")
+}
+
+func (c *Context) GetString(key string) (s string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		s, _ = val.(string)
+	}
+	return
+}
+
+func (c *Context) GetBool(key string) (b bool) {
+	if val, ok := c.Get(key); ok && val != nil {
+		b, _ = val.(bool)
+	}
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(int)
+	}
+	return
+}
+
+func (c *Context) GetInt64(key string) (i64 int64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i64, _ = val.(int64)
+	}
+	return
+}
+
+func (c *Context) GetUint(key string) (ui uint) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ui, _ = val.(uint)
+	}
+	return
+}
+
+func (c *Context) GetFloat64(key string) (f64 float64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		f64, _ = val.(float64)
+	}
+	return
+}
+
+func (c *Context) GetTime(key string) (t time.Time) {
+	if val, ok := c.Get(key); ok && val != nil {
+		t, _ = val.(time.Time)
+	}
+	return
+}
+
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if val, ok := c.Get(key); ok && val != nil {
+		d, _ = val.(time.Duration)
+	}
+	return
+}
+
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ss, _ = val.([]string)
+	}
+	return
+}
+
+func (c *Context) GetStringMap(key string) (sm map[string]interface{}) {
+	if val, ok := c.Get(key); ok && val != nil {
+		sm, _ = val.(map[string]interface{})
+	}
+	return
+}
+
+// Context 实现 context.Context, 委托给 Request.Context()
+// 使 *Context 可以直接传给数据库、dc.Group 或 rpc.Client.Call 等调用
+
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.Request == nil {
+		return
+	}
+	return c.Request.Context().Deadline()
+}
+
+func (c *Context) Done() <-chan struct{} {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Done()
+}
+
+func (c *Context) Err() error {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Err()
+}
+
+// Value 优先查找通过 Set 保存的字符串键, 其他键交给 Request.Context()
+func (c *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if val, exists := c.Get(k); exists {
+			return val
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Value(key)
+}
+
 func (c *Context) PostForm(key string) string {
+	_ = c.parseMultipartForm()
 	return c.Request.FormValue(key)
 }
 
@@ -56,43 +284,289 @@ func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// Render 写出状态码和 r, 失败时记录 ErrorTypeRender 错误并中止后续处理
+func (c *Context) Render(code int, r Render) {
+	c.Status(code)
+	if !bodyAllowedForStatus(code) {
+		r.WriteContentType(c.Writer)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	if err := r.Render(c.Writer); err != nil {
+		c.Error(err).SetType(ErrorTypeRender)
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Abort()
+	}
+}
+
 func (c *Context) JSON(code int, obj interface{}) {
-	c.SetHeader("Content-Type", "application/json")
+	c.Render(code, JSONRender{Data: obj})
+}
+
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, IndentedJSONRender{Data: obj})
+}
+
+// SecureJSON 在顶层为数组时加上 Engine.SecureJSONPrefix
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, SecureJSONRender{Prefix: c.engine.SecureJSONPrefix, Data: obj})
+}
+
+// JSONP 使用查询参数 callback 作为回调函数名, 没有时输出 JSON
+func (c *Context) JSONP(code int, obj interface{}) {
+	c.Render(code, JSONPRender{Callback: c.Query("callback"), Data: obj})
+}
+
+func (c *Context) AsciiJSON(code int, obj interface{}) {
+	c.Render(code, AsciiJSONRender{Data: obj})
+}
+
+func (c *Context) PureJSON(code int, obj interface{}) {
+	c.Render(code, PureJSONRender{Data: obj})
+}
+
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, XMLRender{Data: obj})
+}
+
+func (c *Context) String(code int, format string, values ...interface{}) {
+	c.Render(code, StringRender{Format: format, Data: values})
+}
+
+func (c *Context) Data(code int, data []byte) {
+	c.Render(code, DataRender{Data: data})
+}
+
+// Stream 反复调用 step 并在每次之后 Flush, 直到 step 返回 false 或客户端断开
+// 返回值表示客户端是否已断开
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// Redirect 重定向到 location, code 通常为 3xx
+func (c *Context) Redirect(code int, location string) {
 	c.Status(code)
+	http.Redirect(c.Writer, c.Request, location, code)
+}
 
-	encoder := json.NewEncoder(c.Writer)
-	err := encoder.Encode(obj)
+func (c *Context) HTML(code int, name string, data interface{}) {
+	c.Render(code, HTMLRender{Template: c.engine.htmlTemplates, Name: name, Data: data})
+}
+
+func (c *Context) Param(key string) string {
+	return c.Params.ByName(key)
+}
+
+// ParamInt 将路径参数解析为 int, 通常与 :id<int> 约束一起使用
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+func (c *Context) ParamUint64(key string) (uint64, error) {
+	return strconv.ParseUint(c.Param(key), 10, 64)
+}
+
+func (c *Context) ParamFloat64(key string) (float64, error) {
+	return strconv.ParseFloat(c.Param(key), 64)
+}
+
+// ClientIP 返回客户端 IP, 请求来自可信代理时依次使用 RemoteIPHeaders
+func (c *Context) ClientIP() string {
+	remoteIP := c.RemoteIP()
+	if remoteIP == "" || c.engine == nil || !c.engine.isTrustedProxy(net.ParseIP(remoteIP)) {
+		return remoteIP
+	}
+	for _, header := range c.engine.RemoteIPHeaders {
+		if ip, ok := c.engine.clientIPFromHeader(c.GetHeader(header)); ok {
+			return ip
+		}
+	}
+	return remoteIP
+}
+
+// RemoteIP 返回 Request.RemoteAddr 中的 IP
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
 	if err != nil {
-		panic(err)
+		return strings.TrimSpace(c.Request.RemoteAddr)
 	}
+	return ip
 }
 
-func (c *Context) String(code int, format string, values ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-	_, err := c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
+// CookieOptions 为 SetCookie 的 cookie 属性, Path 为空时使用 "/"
+type CookieOptions struct {
+	Path   string
+	Domain string
+	// MaxAge 为 0 时为会话 cookie, 小于 0 时删除 cookie
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	// SameSite 为 http.SameSiteNoneMode 时浏览器要求 Secure, 会自动设置
+	SameSite http.SameSite
+}
+
+// Cookie 返回请求中名为 name 的 cookie 值, 值已经过 URL 解码
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Request.Cookie(name)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
+	return url.QueryUnescape(cookie.Value)
 }
 
-func (c *Context) Data(code int, data []byte) {
-	c.Status(code)
-	_, err := c.Writer.Write(data)
+// SetCookie 添加 Set-Cookie 响应头, value 会经过 URL 编码
+func (c *Context) SetCookie(name, value string, opts CookieOptions) {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == http.SameSiteNoneMode {
+		opts.Secure = true
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   opts.MaxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	})
+}
+
+func (c *Context) GetHeader(key string) string {
+	return c.Request.Header.Get(key)
+}
+
+// ContentType 返回不带参数的 Content-Type
+func (c *Context) ContentType() string {
+	contentType := c.GetHeader("Content-Type")
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// Bind 根据请求方法和 Content-Type 选择 Binding, 失败时返回 400
+func (c *Context) Bind(obj interface{}) error {
+	return c.BindWith(obj, defaultBinding(c.Method, c.ContentType()))
+}
+
+func (c *Context) BindJSON(obj interface{}) error {
+	return c.BindWith(obj, BindingJSON)
+}
+
+func (c *Context) BindXML(obj interface{}) error {
+	return c.BindWith(obj, BindingXML)
+}
+
+func (c *Context) BindQuery(obj interface{}) error {
+	return c.BindWith(obj, BindingQuery)
+}
+
+func (c *Context) BindForm(obj interface{}) error {
+	return c.BindWith(obj, BindingForm)
+}
+
+func (c *Context) BindHeader(obj interface{}) error {
+	return c.BindWith(obj, BindingHeader)
+}
+
+func (c *Context) BindURI(obj interface{}) error {
+	err := c.ShouldBindURI(obj)
 	if err != nil {
-		panic(err)
+		c.bindFailed(err)
 	}
+	return err
 }
 
-func (c *Context) HTML(code int, html string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, html, data)
+func (c *Context) BindWith(obj interface{}, b Binding) error {
+	err := c.ShouldBindWith(obj, b)
 	if err != nil {
-		panic(err)
+		c.bindFailed(err)
 	}
+	return err
 }
 
-func (c *Context) Param(key string) string {
-	return c.Params[key]
+// bindFailed 以统一的 JSON 格式返回绑定和校验错误, binding tag 有误时返回 500
+func (c *Context) bindFailed(err error) {
+	var ire *InvalidRuleError
+	if errors.As(err, &ire) {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, H{"error": "Internal Server Error"})
+		return
+	}
+	c.Error(err).SetType(ErrorTypeBind)
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, H{"error": "validation failed", "fields": ve})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, H{"error": err.Error()})
+}
+
+// ShouldBind 与 Bind 相同, 但不写响应, 由调用者处理错误
+func (c *Context) ShouldBind(obj interface{}) error {
+	return c.ShouldBindWith(obj, defaultBinding(c.Method, c.ContentType()))
+}
+
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingJSON)
+}
+
+func (c *Context) ShouldBindXML(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingXML)
+}
+
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingQuery)
+}
+
+func (c *Context) ShouldBindForm(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingForm)
+}
+
+func (c *Context) ShouldBindHeader(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingHeader)
+}
+
+func (c *Context) ShouldBindURI(obj interface{}) error {
+	if err := bindURI(c.Params, obj); err != nil {
+		return err
+	}
+	return c.engine.validator.Validate(obj)
+}
+
+// ShouldBindWith 绑定后按 binding tag 校验, 校验失败返回 ValidationErrors
+func (c *Context) ShouldBindWith(obj interface{}, b Binding) error {
+	// 先按 Engine.MaxMultipartMemory 解析, BindingForm 不会再以默认大小解析
+	if b == BindingForm {
+		if err := c.parseMultipartForm(); err != nil {
+			return &BindError{Source: "form", Err: err}
+		}
+	}
+	if err := b.Bind(c.Request, obj); err != nil {
+		return err
+	}
+	return c.engine.validator.Validate(obj)
 }
@@ -0,0 +1,202 @@
+package wf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextReuse(t *testing.T) {
+	r := New()
+	var mu sync.Mutex
+	seen := make(map[*Context]int)
+	check := func(params int) HandlerFunc {
+		return func(c *Context) {
+			mu.Lock()
+			seen[c]++
+			mu.Unlock()
+			if c.StatusCode != 0 || c.index != 0 || len(c.Params) != params || len(c.handlers) != 2 {
+				panic(fmt.Sprintf("leaked state: status=%d index=%d params=%v handlers=%d",
+					c.StatusCode, c.index, c.Params, len(c.handlers)))
+			}
+		}
+	}
+	r.GET("/a/:x/:y", check(2), func(c *Context) { c.String(http.StatusCreated, c.Param("x")+c.Param("y")) })
+	r.GET("/b", check(0), func(c *Context) { c.String(http.StatusOK, c.Param("x")) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				// require 只能在测试 goroutine 中调用
+				w := performRequest(r, "GET", "/a/1/2")
+				assert.Equal(t, http.StatusCreated, w.Code)
+				assert.Equal(t, "12", w.Body.String())
+
+				w = performRequest(r, "GET", "/b")
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Empty(t, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	reused := false
+	for _, n := range seen {
+		reused = reused || n > 1
+	}
+	require.True(t, reused, "contexts should be reused")
+}
+
+func TestContextCopy(t *testing.T) {
+	r := New()
+	copies := make(chan *Context, 1)
+	r.GET("/users/:id", func(c *Context) {
+		c.Status(http.StatusAccepted)
+		copies <- c.Copy()
+	})
+	r.GET("/other/:name", func(c *Context) {})
+
+	performRequest(r, "GET", "/users/42")
+	cp := <-copies
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, "42", cp.Param("id"))
+			assert.Equal(t, "/users/42", cp.Path)
+			assert.Equal(t, http.StatusAccepted, cp.StatusCode)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		performRequest(r, "GET", "/other/x")
+	}
+	wg.Wait()
+	require.Nil(t, cp.Writer)
+	require.Empty(t, cp.handlers)
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {})
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	w := &discardWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
+
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func TestContextKeys(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.Set("user", "geek")
+		c.Set("id", 42)
+		c.Set("admin", true)
+		c.Set("ttl", time.Second)
+		c.Next()
+	})
+	r.GET("/me", func(c *Context) {
+		require.Equal(t, "geek", c.MustGet("user"))
+		require.Equal(t, "geek", c.GetString("user"))
+		require.Equal(t, 42, c.GetInt("id"))
+		require.True(t, c.GetBool("admin"))
+		require.Equal(t, time.Second, c.GetDuration("ttl"))
+		require.Zero(t, c.GetInt64("id"))
+		require.Empty(t, c.GetString("missing"))
+		require.Panics(t, func() { c.MustGet("missing") })
+
+		cp := c.Copy()
+		c.Set("user", "other")
+		require.Equal(t, "geek", cp.GetString("user"))
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/empty", func(c *Context) {
+		_, exists := c.Get("user")
+		require.False(t, exists)
+		require.Nil(t, c.Keys)
+	})
+
+	require.Equal(t, http.StatusOK, performRequest(r, "GET", "/me").Code)
+	performRequest(r, "GET", "/me")
+}
+
+type ctxKey struct{}
+
+func TestContextAsContext(t *testing.T) {
+	r := New()
+	r.GET("/ctx", func(c *Context) {
+		var ctx context.Context = c
+		c.Set("request_id", "abc")
+		require.Equal(t, "abc", ctx.Value("request_id"))
+		require.Equal(t, "parent", ctx.Value(ctxKey{}))
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.False(t, deadline.IsZero())
+
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		<-cancelCtx.Done()
+		require.NoError(t, ctx.Err())
+		c.String(http.StatusOK, "ok")
+	})
+
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "parent"), time.Minute)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(parent, "GET", "/ctx", nil)
+	w := &discardWriter{header: make(http.Header)}
+	r.ServeHTTP(w, req)
+
+	cancel()
+	c := &Context{Request: req}
+	<-c.Done()
+	require.ErrorIs(t, c.Err(), context.Canceled)
+
+	empty := &Context{}
+	require.Nil(t, empty.Done())
+	require.Nil(t, empty.Value("x"))
+}
+
+func TestCookie(t *testing.T) {
+	r := New()
+	r.GET("/cookie", func(c *Context) {
+		v, err := c.Cookie("lang")
+		if err != nil {
+			v = "none"
+		}
+		c.SetCookie("greeting", "hello world;", CookieOptions{MaxAge: 60, HttpOnly: true, SameSite: http.SameSiteNoneMode})
+		c.String(http.StatusOK, v)
+	})
+
+	req := httptest.NewRequest("GET", "/cookie", nil)
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "zh%20CN"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, "zh CN", w.Body.String())
+	require.Equal(t, "greeting=hello+world%3B; Path=/; Max-Age=60; HttpOnly; Secure; SameSite=None", w.Header().Get("Set-Cookie"))
+
+	w = performRequest(r, "GET", "/cookie")
+	require.Equal(t, "none", w.Body.String())
+}
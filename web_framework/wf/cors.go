@@ -0,0 +1,167 @@
+package wf
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig 配置跨域资源共享
+type CORSConfig struct {
+	// AllowOrigins 支持精确匹配、"*" 和 "https://*.example.com" 形式的子域名通配
+	AllowOrigins []string
+	// AllowOriginFunc 在 AllowOrigins 没有匹配时判断 origin 是否允许
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 默认为 GET, HEAD, POST, PUT, PATCH, DELETE
+	AllowMethods []string
+	// AllowHeaders 为空时允许预检请求中 Access-Control-Request-Headers 列出的所有请求头
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// AllowCredentials 为 true 时, 即使配置了 "*" 也会返回具体的 origin
+	AllowCredentials bool
+	// MaxAge 为浏览器缓存预检结果的时间, 精度为秒
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+type corsPolicy struct {
+	conf          CORSConfig
+	allowAll      bool
+	origins       map[string]struct{}
+	wildcards     [][2]string
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// CORS 返回处理跨域请求的中间件
+// 在 Engine 上使用时, 没有注册 OPTIONS 路由的预检请求也会被应答;
+// 只在分组上使用时, 需要为分组注册 OPTIONS 路由, 例如 group.OPTIONS("/*path")
+func CORS(conf CORSConfig) HandlerFunc {
+	p := newCORSPolicy(conf)
+	return func(c *Context) {
+		header := c.Writer.Header()
+		origin := c.GetHeader("Origin")
+		preflight := c.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// 响应随 Origin 变化时, 没有 Origin 或 Origin 不允许的响应也要声明, 防止被缓存后用于其他来源
+		if !p.allowAll || p.conf.AllowCredentials {
+			addVary(header, "Origin")
+		}
+		if preflight {
+			addVary(header, "Access-Control-Request-Method")
+			addVary(header, "Access-Control-Request-Headers")
+		}
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !p.allowOrigin(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if p.allowAll && !p.conf.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.conf.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if p.exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		header.Del("Allow")
+		header.Set("Access-Control-Allow-Methods", p.allowMethods)
+		if allowHeaders := p.allowHeaders; allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if p.maxAge != "" {
+			header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func newCORSPolicy(conf CORSConfig) *corsPolicy {
+	if len(conf.AllowOrigins) == 0 && conf.AllowOriginFunc == nil {
+		panic("CORS: AllowOrigins or AllowOriginFunc is required")
+	}
+	p := &corsPolicy{conf: conf, origins: make(map[string]struct{})}
+	for _, origin := range conf.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch n := strings.Count(origin, "*"); {
+		case origin == "*":
+			p.allowAll = true
+		case n == 1:
+			i := strings.IndexByte(origin, '*')
+			p.wildcards = append(p.wildcards, [2]string{origin[:i], origin[i+1:]})
+		case n > 1:
+			panic("CORS: invalid origin pattern: " + origin)
+		default:
+			p.origins[origin] = struct{}{}
+		}
+	}
+
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	p.allowMethods = strings.ToUpper(strings.Join(methods, ", "))
+	p.allowHeaders = strings.Join(conf.AllowHeaders, ", ")
+	p.exposeHeaders = strings.Join(conf.ExposeHeaders, ", ")
+	if conf.MaxAge > 0 {
+		p.maxAge = strconv.FormatInt(int64(conf.MaxAge/time.Second), 10)
+	}
+	return p
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := p.origins[lower]; ok {
+		return true
+	}
+	for _, w := range p.wildcards {
+		// 通配部分至少有一个字符, 且不能跨越 scheme 或路径
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) &&
+			!strings.ContainsAny(lower[len(w[0]):len(lower)-len(w[1])], "/:") {
+			return true
+		}
+	}
+	return p.conf.AllowOriginFunc != nil && p.conf.AllowOriginFunc(origin)
+}
+
+// addVary 向 Vary 响应头添加 value, 已存在时不重复添加
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
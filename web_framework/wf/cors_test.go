@@ -0,0 +1,115 @@
+package wf
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	r := New()
+	r.Use(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return strings.HasSuffix(origin, ".test") },
+		AllowMethods:     []string{"GET", "post"},
+		AllowHeaders:     []string{"Content-Type", "X-Token"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	r.GET("/users", func(c *Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		c.String(http.StatusOK, "users")
+	})
+
+	w := performRequest(r, "GET", "/users", withHeader("Origin", "https://app.example.com"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "X-Total", w.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, []string{"Origin", "Accept-Encoding"}, w.Header().Values("Vary"))
+
+	for origin, allowed := range map[string]bool{
+		"https://a.example.org":         true,
+		"https://a.b.example.org":       true,
+		"HTTPS://A.EXAMPLE.ORG":         true,
+		"https://example.org":           false,
+		"http://a.example.org":          false,
+		"https://evil.com/.example.org": false,
+		"http://local.test":             true,
+		"https://evil.com":              false,
+	} {
+		w = performRequest(r, "GET", "/users", withHeader("Origin", origin))
+		require.Equal(t, http.StatusOK, w.Code, origin)
+		if allowed {
+			require.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		} else {
+			require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+		require.Equal(t, "Origin", w.Header().Get("Vary"), origin)
+	}
+
+	// 没有 Origin 的请求同样声明 Vary
+	w = performRequest(r, "GET", "/users")
+	require.Equal(t, "users", w.Body.String())
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "Origin", w.Header().Values("Vary")[0])
+
+	// 没有注册 OPTIONS 路由时仍然应答预检请求
+	w = performRequest(r, "OPTIONS", "/users", withHeader("Origin", "https://app.example.com"),
+		withHeader("Access-Control-Request-Method", "POST"), withHeader("Access-Control-Request-Headers", "content-type"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Empty(t, w.Header().Get("Allow"))
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "Content-Type, X-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+
+	w = performRequest(r, "OPTIONS", "/users", withHeader("Origin", "https://evil.com"), withHeader("Access-Control-Request-Method", "POST"))
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// 不是预检的 OPTIONS 请求按原来的方式处理
+	w = performRequest(r, "OPTIONS", "/users", withHeader("Origin", "https://app.example.com"))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSAllowAll(t *testing.T) {
+	r := New()
+	r.Use(CORS(CORSConfig{AllowOrigins: []string{"*"}}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	api := r.Group("/api")
+	api.OPTIONS("/items", func(c *Context) { c.String(http.StatusOK, "options") })
+
+	w := performRequest(r, "GET", "/", withHeader("Origin", "https://any.com"))
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Empty(t, w.Header().Get("Vary"))
+
+	// 预检请求不进入已注册的 OPTIONS 路由, 请求头为空时回显请求的头
+	w = performRequest(r, "OPTIONS", "/api/items", withHeader("Origin", "https://any.com"),
+		withHeader("Access-Control-Request-Method", "DELETE"), withHeader("Access-Control-Request-Headers", "X-A, X-B"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "X-A, X-B", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+
+	// 允许凭据时不能返回 "*"
+	r = New()
+	r.Use(CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	w = performRequest(r, "GET", "/", withHeader("Origin", "https://any.com"))
+	require.Equal(t, "https://any.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	require.Panics(t, func() { CORS(CORSConfig{}) })
+	require.Panics(t, func() { CORS(CORSConfig{AllowOrigins: []string{"https://*.*.com"}}) })
+}
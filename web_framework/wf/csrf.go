@@ -0,0 +1,291 @@
+package wf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const (
+	csrfKey         = "wf/csrf"
+	csrfTokenLength = 32
+)
+
+var (
+	ErrCSRFToken  = errors.New("csrf: token is missing or invalid")
+	ErrCSRFOrigin = errors.New("csrf: origin is not allowed")
+)
+
+// CSRFStore 保存 synchronizer token 模式下的令牌, 通常保存在服务端会话中
+type CSRFStore interface {
+	// Token 返回已保存的令牌, 没有时返回空字符串
+	Token(c *Context) (string, error)
+	SaveToken(c *Context, token string) error
+}
+
+// CSRFConfig 配置 CSRF 防护
+type CSRFConfig struct {
+	// Store 为空时使用 double-submit cookie 模式, 令牌保存在 Cookie 中;
+	// 不为空时使用 synchronizer token 模式, 令牌保存在 Store 中
+	Store CSRFStore
+	// Cookie 为 double-submit cookie 的名称, 默认为 "_csrf"
+	Cookie        string
+	CookieOptions CookieOptions
+	// Secret 不为空时 double-submit cookie 带有 HMAC 签名, 防止能写入 cookie 的兄弟子域名预先设置令牌
+	// 不能信任兄弟子域名时, 需要设置 Secret 或使用 Store
+	Secret []byte
+	// FieldName 为表单字段名, 默认为 "_csrf"
+	FieldName string
+	// HeaderName 为 AJAX 请求携带令牌的请求头, 默认为 "X-CSRF-Token"
+	HeaderName string
+	// ExemptPaths 为不校验的请求路径, 使用 path.Match 匹配, 例如 "/webhooks/*"
+	ExemptPaths []string
+	// Exempt 返回 true 时不校验
+	Exempt func(c *Context) bool
+	// TrustedOrigins 为 HTTPS 请求额外允许的来源, 例如 "https://admin.example.com"
+	TrustedOrigins []string
+	// ErrorHandler 处理校验失败的请求, 默认返回 403, 原因记录在 c.Errors 中
+	ErrorHandler HandlerFunc
+}
+
+type csrfState struct {
+	token     []byte
+	fieldName string
+}
+
+// CSRF 返回 CSRF 防护中间件
+// GET、HEAD、OPTIONS 和 TRACE 不校验, 其他请求需要在 HeaderName 头或 FieldName 表单字段中携带令牌,
+// HTTPS 请求还要求 Origin 或 Referer 与请求同源或在 TrustedOrigins 中
+// 模板通过 CSRFFuncMap 中的 csrfToken 和 csrfField 取得令牌, 每次取得的令牌经过随机掩码, 不会被 BREACH 攻击还原
+func CSRF(conf CSRFConfig) HandlerFunc {
+	if conf.Cookie == "" {
+		conf.Cookie = "_csrf"
+	}
+	if conf.FieldName == "" {
+		conf.FieldName = "_csrf"
+	}
+	if conf.HeaderName == "" {
+		conf.HeaderName = "X-CSRF-Token"
+	}
+	if conf.CookieOptions == (CookieOptions{}) {
+		conf.CookieOptions = CookieOptions{HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	}
+	for _, pattern := range conf.ExemptPaths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic("Invalid CSRF exempt path: " + pattern)
+		}
+	}
+	trusted := make(map[string]bool, len(conf.TrustedOrigins))
+	for _, origin := range conf.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	if conf.ErrorHandler == nil {
+		conf.ErrorHandler = func(c *Context) { c.AbortWithStatus(http.StatusForbidden) }
+	}
+
+	return func(c *Context) {
+		token, err := conf.loadToken(c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		if token == nil {
+			if token, err = conf.newToken(c); err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		c.Set(csrfKey, csrfState{token: token, fieldName: conf.FieldName})
+
+		switch c.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		if conf.exempt(c) {
+			c.Next()
+			return
+		}
+
+		if isHTTPS(c) && !checkCSRFOrigin(c, trusted) {
+			c.Error(ErrCSRFOrigin).SetType(ErrorTypePublic)
+			conf.ErrorHandler(c)
+			c.Abort()
+			return
+		}
+		sent := c.GetHeader(conf.HeaderName)
+		if sent == "" {
+			sent = c.PostForm(conf.FieldName)
+		}
+		if submitted := unmaskCSRFToken(sent); submitted == nil || subtle.ConstantTimeCompare(submitted, token) != 1 {
+			c.Error(ErrCSRFToken).SetType(ErrorTypePublic)
+			conf.ErrorHandler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken 返回经过掩码的令牌, 用于表单字段或页面中供 AJAX 读取的 meta 标签
+// 没有使用 CSRF 中间件时返回空字符串
+func CSRFToken(c *Context) string {
+	state, ok := c.Get(csrfKey)
+	if !ok {
+		return ""
+	}
+	return maskCSRFToken(state.(csrfState).token)
+}
+
+// CSRFField 返回包含令牌的隐藏表单字段
+func CSRFField(c *Context) string {
+	state, ok := c.Get(csrfKey)
+	if !ok {
+		return ""
+	}
+	return `<input type="hidden" name="` + template.HTMLEscapeString(state.(csrfState).fieldName) +
+		`" value="` + CSRFToken(c) + `">`
+}
+
+// CSRFFuncMap 返回模板函数 csrfToken 和 csrfField, 参数为 *Context, 例如 {{ csrfField .ctx }}
+// 可以与其他函数合并后通过 SetFuncMap 注册
+func CSRFFuncMap() template.FuncMap {
+	return template.FuncMap{"csrfToken": CSRFToken, "csrfField": CSRFField}
+}
+
+// loadToken 返回已保存的令牌, 没有、格式不对或签名不对时返回 nil
+func (conf *CSRFConfig) loadToken(c *Context) ([]byte, error) {
+	var encoded string
+	if conf.Store != nil {
+		stored, err := conf.Store.Token(c)
+		if err != nil {
+			return nil, err
+		}
+		encoded = stored
+	} else {
+		value, _ := c.Cookie(conf.Cookie)
+		if encoded = conf.verifyCookie(value); encoded == "" {
+			return nil, nil
+		}
+	}
+	token, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(token) != csrfTokenLength {
+		return nil, nil
+	}
+	return token, nil
+}
+
+func (conf *CSRFConfig) newToken(c *Context) ([]byte, error) {
+	token := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+	if conf.Store != nil {
+		return token, conf.Store.SaveToken(c, encoded)
+	}
+	opts := conf.CookieOptions
+	if isHTTPS(c) {
+		opts.Secure = true
+	}
+	c.SetCookie(conf.Cookie, conf.signCookie(encoded), opts)
+	return token, nil
+}
+
+// signCookie 在设置了 Secret 时返回 令牌.base64(HMAC), HMAC 覆盖 cookie 名
+func (conf *CSRFConfig) signCookie(encoded string) string {
+	if len(conf.Secret) == 0 {
+		return encoded
+	}
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(conf.cookieMAC(encoded))
+}
+
+// verifyCookie 返回 cookie 中的令牌, 签名不对时返回空字符串
+func (conf *CSRFConfig) verifyCookie(value string) string {
+	if len(conf.Secret) == 0 {
+		return value
+	}
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return ""
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, conf.cookieMAC(encoded)) {
+		return ""
+	}
+	return encoded
+}
+
+func (conf *CSRFConfig) cookieMAC(encoded string) []byte {
+	h := hmac.New(sha256.New, conf.Secret)
+	h.Write([]byte(conf.Cookie + "|" + encoded))
+	return h.Sum(nil)
+}
+
+func (conf *CSRFConfig) exempt(c *Context) bool {
+	for _, pattern := range conf.ExemptPaths {
+		if ok, _ := path.Match(pattern, c.Request.URL.Path); ok {
+			return true
+		}
+	}
+	return conf.Exempt != nil && conf.Exempt(c)
+}
+
+// maskCSRFToken 返回 base64(otp || otp^token), 每次的结果都不同
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*len(token))
+	if _, err := rand.Read(masked[:len(token)]); err != nil {
+		return ""
+	}
+	for i, b := range token {
+		masked[len(token)+i] = masked[i] ^ b
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func unmaskCSRFToken(sent string) []byte {
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != 2*csrfTokenLength {
+		return nil
+	}
+	token := make([]byte, csrfTokenLength)
+	for i := range token {
+		token[i] = masked[i] ^ masked[csrfTokenLength+i]
+	}
+	return token
+}
+
+// isHTTPS 在请求直接使用 TLS, 或来自可信代理且 X-Forwarded-Proto 为 https 时返回 true
+func isHTTPS(c *Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	return c.engine != nil && c.engine.isTrustedProxy(net.ParseIP(c.RemoteIP())) &&
+		strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+// checkCSRFOrigin 要求 Origin 存在时与请求同源, 否则 Referer 必须存在且同源, 防止 HTTP 中间人伪造 HTTPS 请求
+func checkCSRFOrigin(c *Context, trusted map[string]bool) bool {
+	source := c.GetHeader("Origin")
+	if source == "" {
+		source = c.GetHeader("Referer")
+	}
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	return origin == "https://"+strings.ToLower(c.Request.Host) || trusted[origin]
+}
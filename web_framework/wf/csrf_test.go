@@ -0,0 +1,154 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRF(t *testing.T) {
+	var errs Errors
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.Use(CSRF(CSRFConfig{ExemptPaths: []string{"/webhooks/*"}, TrustedOrigins: []string{"https://admin.example.com/"}}))
+	r.GET("/form", func(c *Context) { c.String(http.StatusOK, CSRFToken(c)) })
+	r.POST("/form", func(c *Context) { c.String(http.StatusOK, "ok") })
+	r.POST("/webhooks/github", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	w := performRequest(r, "GET", "/form")
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	cookie := cookies[0]
+	require.Equal(t, "_csrf", cookie.Name)
+	require.True(t, cookie.HttpOnly)
+	require.False(t, cookie.Secure)
+	token := w.Body.String()
+
+	// 已有 cookie 时不重新生成, 每次返回的令牌都经过不同的掩码
+	w = performRequest(r, "GET", "/form", withCookie(cookie))
+	require.Empty(t, w.Result().Cookies())
+	require.NotEqual(t, token, w.Body.String())
+	other := w.Body.String()
+
+	w = performRequest(r, "POST", "/form", withCookie(cookie), withForm(url.Values{"_csrf": {token}}))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "POST", "/form", withCookie(cookie), withHeader("X-CSRF-Token", other))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, sent := range []string{"", "invalid", token[:len(token)-2]} {
+		w = performRequest(r, "POST", "/form", withCookie(cookie), withForm(url.Values{"_csrf": {sent}}))
+		require.Equal(t, http.StatusForbidden, w.Code, sent)
+		require.Len(t, errs, 1)
+		require.Equal(t, ErrCSRFToken, errs[0].Err)
+	}
+	// 令牌与其他用户的 cookie 不匹配
+	w = performRequest(r, "GET", "/form")
+	w = performRequest(r, "POST", "/form", withCookie(w.Result().Cookies()[0]), withForm(url.Values{"_csrf": {token}}))
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = performRequest(r, "POST", "/webhooks/github")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// HTTPS 请求还需要同源或可信的 Origin / Referer
+	form := withForm(url.Values{"_csrf": {token}})
+	for origin, code := range map[string]int{
+		"https://example.com":       http.StatusOK,
+		"https://admin.example.com": http.StatusOK,
+		"http://example.com":        http.StatusForbidden,
+		"https://evil.com":          http.StatusForbidden,
+		"null":                      http.StatusForbidden,
+	} {
+		w = performRequest(r, "POST", "https://example.com/form", withCookie(cookie), form, withHeader("Origin", origin))
+		require.Equal(t, code, w.Code, origin)
+		if code == http.StatusForbidden {
+			require.Equal(t, ErrCSRFOrigin, errs[0].Err)
+		}
+	}
+	w = performRequest(r, "POST", "https://example.com/form", withCookie(cookie), form, withHeader("Referer", "https://example.com/form"))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "POST", "https://example.com/form", withCookie(cookie), form)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = performRequest(r, "GET", "https://example.com/form")
+	require.True(t, w.Result().Cookies()[0].Secure)
+
+	require.Panics(t, func() { CSRF(CSRFConfig{ExemptPaths: []string{"["}}) })
+}
+
+func TestCSRFSignedCookie(t *testing.T) {
+	r := New()
+	r.Use(CSRF(CSRFConfig{Secret: []byte("csrf-secret")}))
+	r.GET("/form", func(c *Context) { c.String(http.StatusOK, CSRFToken(c)) })
+	r.POST("/form", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	w := performRequest(r, "GET", "/form")
+	cookie := w.Result().Cookies()[0]
+	require.Contains(t, cookie.Value, ".")
+	w = performRequest(r, "POST", "/form", withCookie(cookie), withHeader("X-CSRF-Token", w.Body.String()))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// 兄弟子域名写入的未签名令牌无效, 并重新签发 cookie
+	fixed := make([]byte, csrfTokenLength)
+	for _, value := range []string{
+		base64.RawURLEncoding.EncodeToString(fixed),
+		base64.RawURLEncoding.EncodeToString(fixed) + ".forged",
+	} {
+		w = performRequest(r, "POST", "/form", withCookie(&http.Cookie{Name: "_csrf", Value: value}),
+			withHeader("X-CSRF-Token", maskCSRFToken(fixed)))
+		require.Equal(t, http.StatusForbidden, w.Code, value)
+		require.NotEqual(t, value, w.Result().Cookies()[0].Value)
+	}
+}
+
+type mapCSRFStore map[string]string
+
+func (s mapCSRFStore) Token(c *Context) (string, error) {
+	return s[c.Query("user")], nil
+}
+
+func (s mapCSRFStore) SaveToken(c *Context, token string) error {
+	s[c.Query("user")] = token
+	return nil
+}
+
+func TestCSRFStore(t *testing.T) {
+	store := mapCSRFStore{}
+	r := New()
+	r.Use(CSRF(CSRFConfig{Store: store, ErrorHandler: func(c *Context) {
+		c.String(http.StatusBadRequest, c.Errors.Last().Error())
+	}}))
+	r.GET("/form", func(c *Context) {
+		tmpl := template.Must(template.New("form").Funcs(CSRFFuncMap()).Parse(`<form>{{ csrfField .ctx }}</form>`))
+		buf := new(bytes.Buffer)
+		require.NoError(t, tmpl.Execute(buf, map[string]interface{}{"ctx": c}))
+		c.String(http.StatusOK, buf.String())
+	})
+	r.POST("/form", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	w := performRequest(r, "GET", "/form?user=geek")
+	require.Empty(t, w.Result().Cookies())
+	require.Len(t, store, 1)
+	body := w.Body.String()
+	require.True(t, strings.HasPrefix(body, `<form><input type="hidden" name="_csrf" value="`), body)
+	token := strings.TrimSuffix(strings.TrimPrefix(body, `<form><input type="hidden" name="_csrf" value="`), `"></form>`)
+
+	w = performRequest(r, "POST", "/form?user=geek", withForm(url.Values{"_csrf": {token}}))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "POST", "/form?user=other", withForm(url.Values{"_csrf": {token}}))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, ErrCSRFToken.Error(), w.Body.String())
+
+	c := newContext(New())
+	require.Empty(t, CSRFToken(c))
+	require.Empty(t, CSRFField(c))
+}
@@ -1,6 +1,11 @@
 package wf
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -30,12 +35,12 @@ func TestGetRoute(t *testing.T) {
 	n, params := r.getRoute("GET", "/hello/myname")
 	require.NotNil(t, n)
 	require.Equal(t, "/hello/:name", n.path)
-	require.Equal(t, "myname", params["name"])
+	require.Equal(t, "myname", params.ByName("name"))
 
 	n, params = r.getRoute("GET", "/assets/images/logo.png")
 	require.NotNil(t, n)
 	require.Equal(t, "/assets/*filepath", n.path)
-	require.Equal(t, "images/logo.png", params["filepath"])
+	require.Equal(t, "images/logo.png", params.ByName("filepath"))
 }
 
 func TestMixPath(t *testing.T) {
@@ -58,12 +63,132 @@ func TestMixPath(t *testing.T) {
 
 	n, p = r.getRoute("GET", "/c/ok/cute.jpg")
 	t.Log(n.path)
-	t.Log(p["last"])
-	t.Log(p["path"])
+	t.Log(p.ByName("last"))
+	t.Log(p.ByName("path"))
 
 	n, p = r.getRoute("GET", "/d/image.png")
 	t.Log(n.path)
-	t.Log(p["path"])
+	t.Log(p.ByName("path"))
 
 	// require.Nil(t, n)
 }
+
+// performRequest 依次用 opts 修改请求后发送, 例如 withHeader
+func performRequest(r http.Handler, method, path string, opts ...func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for _, opt := range opts {
+		opt(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func withHeader(key, value string) func(*http.Request) {
+	return func(req *http.Request) { req.Header.Set(key, value) }
+}
+
+func withCookie(cookie *http.Cookie) func(*http.Request) {
+	return func(req *http.Request) { req.AddCookie(cookie) }
+}
+
+// withForm 将 form 编码为 application/x-www-form-urlencoded 请求体
+func withForm(form url.Values) func(*http.Request) {
+	return func(req *http.Request) {
+		body := form.Encode()
+		req.Body = io.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+}
+
+func TestMethods(t *testing.T) {
+	r := New()
+	handler := func(c *Context) { c.String(http.StatusOK, c.Method) }
+	r.PUT("/res", handler)
+	r.DELETE("/res", handler)
+	r.PATCH("/res", handler)
+	r.OPTIONS("/res", handler)
+	r.Handle("PROPFIND", "/res", handler)
+	r.Any("/any", handler)
+	require.Panics(t, func() { r.Handle("get", "/lower", handler) })
+
+	for _, method := range []string{"PUT", "DELETE", "PATCH", "OPTIONS", "PROPFIND"} {
+		w := performRequest(r, method, "/res")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, method, w.Body.String())
+	}
+	for _, method := range anyMethods {
+		w := performRequest(r, method, "/any")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestHeadFallback(t *testing.T) {
+	r := New()
+	r.GET("/get", func(c *Context) { c.String(http.StatusOK, "get") })
+	r.GET("/both", func(c *Context) { c.String(http.StatusOK, "get") })
+	r.HEAD("/both", func(c *Context) { c.Status(http.StatusNoContent) })
+
+	w := performRequest(r, "HEAD", "/get")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = performRequest(r, "HEAD", "/both")
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", nil)
+	r.PUT("/users/:id", nil)
+	r.POST("/users", nil)
+
+	w := performRequest(r, "DELETE", "/users/1")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "GET, HEAD, PUT", w.Header().Get("Allow"))
+
+	w = performRequest(r, "GET", "/users")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "POST", w.Header().Get("Allow"))
+
+	w = performRequest(r, "GET", "/none")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Empty(t, w.Header().Get("Allow"))
+}
+
+func TestNoRouteMiddleware(t *testing.T) {
+	r := New()
+	var logged []int
+	r.Use(func(c *Context) {
+		c.Next()
+		logged = append(logged, c.Writer.Status())
+	})
+	r.GET("/users", func(c *Context) { c.String(http.StatusOK, "users") })
+
+	w := performRequest(r, "GET", "/none")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "404 NOT FOUND: /none\n", w.Body.String())
+
+	w = performRequest(r, "POST", "/users")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, []int{http.StatusNotFound, http.StatusMethodNotAllowed}, logged)
+
+	r.NoRoute(func(c *Context) { c.JSON(http.StatusNotFound, H{"error": "not found"}) })
+	r.NoMethod(func(c *Context) {
+		c.JSON(http.StatusMethodNotAllowed, H{"allow": c.Writer.Header().Get("Allow")})
+	})
+
+	w = performRequest(r, "GET", "/none")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.JSONEq(t, `{"error":"not found"}`, w.Body.String())
+
+	w = performRequest(r, "DELETE", "/users")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.JSONEq(t, `{"allow":"GET, HEAD"}`, w.Body.String())
+
+	// NoRoute 之后注册的全局中间件同样生效
+	r.Use(func(c *Context) { c.SetHeader("X-Late", "1") })
+	w = performRequest(r, "GET", "/none")
+	require.Equal(t, "1", w.Header().Get("X-Late"))
+	require.Len(t, logged, 5)
+}
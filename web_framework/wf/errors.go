@@ -0,0 +1,124 @@
+package wf
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrorType uint64
+
+const (
+	// ErrorTypeBind 由 Bind 失败产生
+	ErrorTypeBind ErrorType = 1 << 63
+	// ErrorTypeRender 由渲染响应失败产生
+	ErrorTypeRender ErrorType = 1 << 62
+	// ErrorTypePrivate 仅用于日志, 不返回给客户端
+	ErrorTypePrivate ErrorType = 1 << 0
+	// ErrorTypePublic 可以返回给客户端
+	ErrorTypePublic ErrorType = 1 << 1
+
+	ErrorTypeAny ErrorType = 1<<64 - 1
+)
+
+// Error 为请求处理过程中通过 Context.Error 收集的错误
+type Error struct {
+	Err  error
+	Type ErrorType
+	Meta interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) SetType(flags ErrorType) *Error {
+	e.Type = flags
+	return e
+}
+
+func (e *Error) SetMeta(meta interface{}) *Error {
+	e.Meta = meta
+	return e
+}
+
+func (e *Error) IsType(flags ErrorType) bool {
+	return e.Type&flags > 0
+}
+
+// JSON 返回用于渲染的值, Meta 为 H 时与错误信息合并
+func (e *Error) JSON() interface{} {
+	h := H{}
+	if m, ok := e.Meta.(H); ok {
+		for k, v := range m {
+			h[k] = v
+		}
+	} else if e.Meta != nil {
+		h["meta"] = e.Meta
+	}
+	if _, ok := h["error"]; !ok {
+		h["error"] = e.Error()
+	}
+	return h
+}
+
+type Errors []*Error
+
+func (es Errors) ByType(flags ErrorType) Errors {
+	if len(es) == 0 {
+		return nil
+	}
+	if flags == ErrorTypeAny {
+		return es
+	}
+	var result Errors
+	for _, e := range es {
+		if e.IsType(flags) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (es Errors) Last() *Error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es[len(es)-1]
+}
+
+func (es Errors) Errors() []string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return msgs
+}
+
+func (es Errors) JSON() interface{} {
+	switch len(es) {
+	case 0:
+		return nil
+	case 1:
+		return es[0].JSON()
+	}
+	list := make([]interface{}, len(es))
+	for i, e := range es {
+		list[i] = e.JSON()
+	}
+	return list
+}
+
+func (es Errors) String() string {
+	var str strings.Builder
+	for i, e := range es {
+		str.WriteString(fmt.Sprintf("Error #%02d: %s\n", i+1, e.Err))
+		if e.Meta != nil {
+			str.WriteString(fmt.Sprintf("     Meta: %v\n", e.Meta))
+		}
+	}
+	return str.String()
+}
@@ -0,0 +1,82 @@
+package wf
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbort(t *testing.T) {
+	r := New()
+	var after []string
+	r.Use(func(c *Context) {
+		c.Next()
+		after = append(after, c.Errors.Errors()...)
+		if c.IsAborted() {
+			after = append(after, "aborted")
+		}
+	})
+	auth := func(c *Context) {
+		if c.Query("token") != "secret" {
+			c.Error(errors.New("bad token")).SetType(ErrorTypePublic)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, c.Errors.ByType(ErrorTypePublic).JSON())
+		}
+	}
+	handled := false
+	r.GET("/private", auth, func(c *Context) {
+		handled = true
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/status", func(c *Context) { c.AbortWithStatus(http.StatusTeapot) }, func(c *Context) { handled = true })
+
+	w := performRequest(r, "GET", "/private")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.JSONEq(t, `{"error":"bad token"}`, w.Body.String())
+	require.False(t, handled)
+	require.Equal(t, []string{"bad token", "aborted"}, after)
+
+	after = nil
+	w = performRequest(r, "GET", "/private?token=secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, handled)
+	require.Empty(t, after)
+
+	handled = false
+	w = performRequest(r, "GET", "/status")
+	require.Equal(t, http.StatusTeapot, w.Code)
+	require.False(t, handled)
+}
+
+func TestErrors(t *testing.T) {
+	c := &Context{}
+	c.Error(errors.New("private"))
+	c.Error(errors.New("public")).SetType(ErrorTypePublic).SetMeta(H{"code": 1})
+	wrapped := &Error{Err: errors.New("bind"), Type: ErrorTypeBind}
+	require.Same(t, wrapped, c.Error(wrapped))
+	require.Panics(t, func() { c.Error(nil) })
+
+	require.Len(t, c.Errors, 3)
+	require.Equal(t, "bind", c.Errors.Last().Error())
+	require.Equal(t, []string{"public"}, c.Errors.ByType(ErrorTypePublic).Errors())
+	require.Equal(t, H{"error": "public", "code": 1}, c.Errors.ByType(ErrorTypePublic).JSON())
+	require.Len(t, c.Errors.ByType(ErrorTypePrivate|ErrorTypeBind), 2)
+	require.Equal(t, c.Errors, c.Errors.ByType(ErrorTypeAny))
+	require.Equal(t, "Error #01: private\nError #02: public\n     Meta: map[code:1]\nError #03: bind\n", c.Errors.String())
+}
+
+func TestRecoveryError(t *testing.T) {
+	r := New()
+	var errs Errors
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	}, Recovery())
+	r.GET("/panic", func(c *Context) { panic("boom") })
+
+	w := performRequest(r, "GET", "/panic")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Len(t, errs, 1)
+	require.Equal(t, "panic recovered: boom", errs[0].Error())
+}
@@ -0,0 +1,210 @@
+package wf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parseMultipartForm 按 Engine.MaxMultipartMemory 解析表单, 非 multipart 请求不视为错误
+func (c *Context) parseMultipartForm() error {
+	err := c.Request.ParseMultipartForm(c.engine.MaxMultipartMemory)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	return nil
+}
+
+// MultipartForm 返回解析后的 multipart 表单, 包括上传的文件
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	err := c.Request.ParseMultipartForm(c.engine.MaxMultipartMemory)
+	return c.Request.MultipartForm, err
+}
+
+// FormFile 返回表单中名为 name 的第一个文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.Request.MultipartForm == nil {
+		if err := c.Request.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil {
+			return nil, err
+		}
+	}
+	f, fh, err := c.Request.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+// SaveUploadedFile 将上传的文件保存到 dst, 会创建不存在的目录
+// file.Filename 由客户端提供, 用于 dst 时应先经过 filepath.Base 等处理
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// File 返回文件内容, 支持 Range 和 If-None-Match、If-Modified-Since 等条件请求
+func (c *Context) File(file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		serveFileError(c, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		serveFileError(c, err)
+		return
+	}
+	if info.IsDir() {
+		serveFileError(c, fs.ErrNotExist)
+		return
+	}
+	c.SetHeader("ETag", modTimeETag(info))
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), f)
+}
+
+// FileAttachment 与 File 相同, 但让浏览器以 filename 下载
+func (c *Context) FileAttachment(file, filename string) {
+	c.SetHeader("Content-Disposition", contentDisposition("attachment", filename))
+	c.File(file)
+}
+
+// contentDisposition 按 RFC 6266 生成 Content-Disposition
+// filename 不是纯 ASCII 时, 同时给出 ASCII 的 filename 和 RFC 5987 编码的 filename*
+func contentDisposition(dispositionType, filename string) string {
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r >= utf8.RuneSelf || r < 0x20 || r == 0x7f:
+			ascii = false
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	if ascii {
+		return fmt.Sprintf(`%s; filename="%s"`, dispositionType, fallback.String())
+	}
+
+	var encoded strings.Builder
+	for i := 0; i < len(filename); i++ {
+		if b := filename[i]; isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, fallback.String(), encoded.String())
+}
+
+// isAttrChar 判断 b 是否为 RFC 5987 中可以不编码的 attr-char
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// DataFromReader 将 reader 的内容写入响应, contentLength 小于 0 表示长度未知
+// code 为 200 且长度已知时与 http.ServeContent 一样处理 Range 和条件请求,
+// extraHeaders 中的 ETag 和 Last-Modified 会参与条件判断
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	for k, v := range extraHeaders {
+		header.Set(k, v)
+	}
+
+	if code == http.StatusOK && contentLength >= 0 {
+		var modtime time.Time
+		if lm := header.Get("Last-Modified"); lm != "" {
+			modtime, _ = http.ParseTime(lm)
+		}
+		rs, ok := reader.(io.ReadSeeker)
+		if !ok {
+			// 只能向前读取时不支持多个区间, 此时忽略 Range 返回完整内容
+			if strings.Contains(c.Request.Header.Get("Range"), ",") {
+				c.Request.Header.Del("Range")
+			}
+			rs = &forwardSeeker{r: reader, size: contentLength}
+		}
+		http.ServeContent(c.Writer, c.Request, "", modtime, rs)
+		return
+	}
+
+	if contentLength >= 0 {
+		header.Set("Content-Length", fmt.Sprint(contentLength))
+	}
+	c.Status(code)
+	if c.Method == http.MethodHead || !bodyAllowedForStatus(code) {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		c.Error(err).SetType(ErrorTypeRender)
+	}
+}
+
+// forwardSeeker 让只能顺序读取的 reader 满足 http.ServeContent 的用法:
+// 先 Seek 到末尾获取长度再回到开头, 之后只向前 Seek 到区间起点
+type forwardSeeker struct {
+	r    io.Reader
+	size int64
+	pos  int64
+}
+
+func (s *forwardSeeker) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *forwardSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekEnd:
+		// 只返回长度, 不移动读取位置
+		return s.size + offset, nil
+	case io.SeekCurrent:
+		offset += s.pos
+	}
+	if offset < s.pos {
+		return s.pos, errors.New("reader can not seek backwards")
+	}
+	if _, err := io.CopyN(io.Discard, s, offset-s.pos); err != nil {
+		return s.pos, err
+	}
+	return s.pos, nil
+}
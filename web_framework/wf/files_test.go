@@ -0,0 +1,149 @@
+package wf
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	r := New()
+	r.MaxMultipartMemory = 8
+	r.POST("/upload", func(c *Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		form, err := c.MultipartForm()
+		require.NoError(t, err)
+		require.Len(t, form.File["file"], 2)
+
+		dst := filepath.Join(dir, "nested", filepath.Base(file.Filename))
+		require.NoError(t, c.SaveUploadedFile(file, dst))
+		c.String(http.StatusOK, "%s %d %s", file.Filename, file.Size, c.PostForm("note"))
+	})
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	require.NoError(t, mw.WriteField("note", "hi"))
+	fw, err := mw.CreateFormFile("file", "../../a.txt")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("hello upload"))
+	fw, err = mw.CreateFormFile("file", "b.txt")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("b"))
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "a.txt 12 hi", w.Body.String())
+	data, err := os.ReadFile(filepath.Join(dir, "nested", "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello upload", string(data))
+
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader("a=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.txt")
+	require.NoError(t, os.WriteFile(file, []byte("0123456789"), 0o644))
+
+	r := New()
+	r.GET("/file", func(c *Context) { c.File(file) })
+	r.GET("/missing", func(c *Context) { c.File(file + ".bak") })
+	r.GET("/download", func(c *Context) { c.FileAttachment(file, "报告 2024.txt") })
+
+	w := performRequest(r, "GET", "/file")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0123456789", w.Body.String())
+	require.NotEmpty(t, w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=-3")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "789", w.Body.String())
+
+	w = performRequest(r, "GET", "/missing")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	r.NoRoute(func(c *Context) { c.AbortWithStatusJSON(http.StatusNotFound, H{"error": "not found"}) })
+	w = performRequest(r, "GET", "/missing")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.JSONEq(t, `{"error":"not found"}`, w.Body.String())
+
+	w = performRequest(r, "GET", "/download")
+	require.Equal(t, `attachment; filename="__ 2024.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A%202024.txt`,
+		w.Header().Get("Content-Disposition"))
+}
+
+func TestContentDisposition(t *testing.T) {
+	require.Equal(t, `attachment; filename="a.txt"`, contentDisposition("attachment", "a.txt"))
+	require.Equal(t, `attachment; filename="say \"hi\".txt"`, contentDisposition("attachment", `say "hi".txt`))
+	require.Equal(t, `inline; filename="_.png"; filename*=UTF-8''%C3%A9.png`, contentDisposition("inline", "é.png"))
+}
+
+func TestDataFromReader(t *testing.T) {
+	r := New()
+	r.GET("/stream", func(c *Context) {
+		reader := io.MultiReader(strings.NewReader("01234"), strings.NewReader("56789"))
+		c.DataFromReader(http.StatusOK, 10, "text/plain", reader, map[string]string{
+			"ETag":                `"v1"`,
+			"Content-Disposition": `attachment; filename="digits.txt"`,
+		})
+	})
+	r.GET("/created", func(c *Context) {
+		c.DataFromReader(http.StatusCreated, -1, "", strings.NewReader("ok"), nil)
+	})
+
+	w := performRequest(r, "GET", "/stream")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0123456789", w.Body.String())
+	require.Equal(t, "10", w.Header().Get("Content-Length"))
+	require.Equal(t, `attachment; filename="digits.txt"`, w.Header().Get("Content-Disposition"))
+
+	send := func(key, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/stream", nil)
+		req.Header.Set(key, value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w = send("Range", "bytes=6-8")
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "678", w.Body.String())
+	w = send("Range", "bytes=6-8,0-1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0123456789", w.Body.String())
+	w = send("If-None-Match", `"v1"`)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = performRequest(r, "GET", "/created")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	require.Equal(t, "ok", w.Body.String())
+}
@@ -2,10 +2,15 @@ module wf
 
 go 1.18
 
-require github.com/stretchr/testify v1.8.0
+require (
+	dc v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.8.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace dc => ../../distributed_cache/dc
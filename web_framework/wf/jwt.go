@@ -0,0 +1,267 @@
+package wf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTClaimsKey 为 JWT 中间件通过 c.Set 保存 JWTClaims 的键
+const JWTClaimsKey = "wf/jwt-claims"
+
+var (
+	ErrTokenMalformed    = errors.New("jwt: token is malformed")
+	ErrTokenUnverifiable = errors.New("jwt: no key for token")
+	ErrTokenSignature    = errors.New("jwt: signature is invalid")
+	ErrTokenExpired      = errors.New("jwt: token is expired")
+	ErrTokenNotValidYet  = errors.New("jwt: token is not valid yet")
+	ErrTokenIssuer       = errors.New("jwt: issuer is invalid")
+	ErrTokenAudience     = errors.New("jwt: audience is invalid")
+)
+
+// JWTClaims 为令牌中的声明, 数字为 json.Number
+type JWTClaims map[string]interface{}
+
+// Subject 返回 sub 声明
+func (cl JWTClaims) Subject() string {
+	s, _ := cl["sub"].(string)
+	return s
+}
+
+// JWTKeySet 保存按 kid 索引的验证密钥, 并发安全, 可以在运行时添加新密钥、删除旧密钥以轮换
+// HS256 的密钥为 []byte, RS256 为 *rsa.PublicKey, ES256 为 P-256 的 *ecdsa.PublicKey
+type JWTKeySet struct {
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+func NewJWTKeySet(keys map[string]interface{}) *JWTKeySet {
+	ks := &JWTKeySet{keys: make(map[string]interface{}, len(keys))}
+	for kid, key := range keys {
+		ks.Set(kid, key)
+	}
+	return ks
+}
+
+func (ks *JWTKeySet) Set(kid string, key interface{}) {
+	if jwtAlgorithm(key) == "" {
+		panic("Invalid JWT key for kid: " + kid)
+	}
+	ks.mu.Lock()
+	ks.keys[kid] = key
+	ks.mu.Unlock()
+}
+
+func (ks *JWTKeySet) Delete(kid string) {
+	ks.mu.Lock()
+	delete(ks.keys, kid)
+	ks.mu.Unlock()
+}
+
+func (ks *JWTKeySet) Key(kid string) (interface{}, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// JWTConfig 配置 JWT 认证
+// 算法由密钥类型决定, 令牌头中的 alg 必须与之一致, 不接受 none
+type JWTConfig struct {
+	// Key 用于没有 kid 的令牌
+	Key interface{}
+	// KeySet 用于带 kid 的令牌
+	KeySet *JWTKeySet
+	// Issuer 不为空时要求 iss 与之相等
+	Issuer string
+	// Audience 不为空时要求 aud 包含它
+	Audience string
+	// Leeway 为校验 exp 和 nbf 时允许的时钟误差
+	Leeway time.Duration
+	// Header、Scheme 和 Query 的含义同 KeyAuthConfig, 默认从 "Authorization: Bearer <token>" 中读取
+	Header string
+	Scheme string
+	Query  string
+}
+
+// JWT 返回 JWT 认证中间件, 校验通过后将声明保存到 JWTClaimsKey, sub 保存到 AuthUserKey
+// 校验失败时返回 401, 原因记录在 c.Errors 中
+func JWT(conf JWTConfig) HandlerFunc {
+	if conf.Key == nil && conf.KeySet == nil {
+		panic("JWT requires a key or a key set")
+	}
+	if conf.Key != nil && jwtAlgorithm(conf.Key) == "" {
+		panic("Invalid JWT key")
+	}
+	src := newTokenSource(conf.Header, conf.Scheme, conf.Query)
+	return func(c *Context) {
+		token := src.extract(c)
+		if token == "" {
+			src.challenge(c, "")
+			return
+		}
+		claims, err := conf.verify(token, time.Now())
+		if err != nil {
+			c.Error(err)
+			src.challenge(c, "invalid_token")
+			return
+		}
+		c.Set(JWTClaimsKey, claims)
+		if sub := claims.Subject(); sub != "" {
+			c.Set(AuthUserKey, sub)
+		}
+		c.Next()
+	}
+}
+
+func (conf *JWTConfig) verify(token string, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	key := conf.Key
+	if header.Kid != "" && conf.KeySet != nil {
+		var ok bool
+		if key, ok = conf.KeySet.Key(header.Kid); !ok {
+			return nil, ErrTokenUnverifiable
+		}
+	}
+	if key == nil {
+		return nil, ErrTokenUnverifiable
+	}
+	if header.Alg != jwtAlgorithm(key) || !verifyJWTSignature(key, parts[0]+"."+parts[1], sig) {
+		return nil, ErrTokenSignature
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrTokenMalformed
+	}
+	if exp, ok, err := claims.numericDate("exp"); err != nil {
+		return nil, err
+	} else if ok && !now.Before(exp.Add(conf.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok, err := claims.numericDate("nbf"); err != nil {
+		return nil, err
+	} else if ok && now.Add(conf.Leeway).Before(nbf) {
+		return nil, ErrTokenNotValidYet
+	}
+	if conf.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != conf.Issuer {
+			return nil, ErrTokenIssuer
+		}
+	}
+	if conf.Audience != "" && !claims.hasAudience(conf.Audience) {
+		return nil, ErrTokenAudience
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// numericDate 读取以秒为单位的时间声明, 可以带小数, 超出 time.Time 纳秒精度范围的值视为格式错误
+func (cl JWTClaims) numericDate(name string) (time.Time, bool, error) {
+	v, ok := cl[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	ns := f * float64(time.Second)
+	// 2^63 本身不能表示为 int64, 所以上界不取等号
+	if math.IsNaN(ns) || ns < math.MinInt64 || ns >= math.MaxInt64 {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	return time.Unix(0, int64(ns)), true, nil
+}
+
+// hasAudience 处理 aud 为字符串或字符串数组两种形式
+func (cl JWTClaims) hasAudience(audience string) bool {
+	switch aud := cl["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwtAlgorithm 返回密钥对应的算法, 不支持的密钥返回空字符串
+func jwtAlgorithm(key interface{}) string {
+	switch k := key.(type) {
+	case []byte:
+		if len(k) > 0 {
+			return "HS256"
+		}
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return "ES256"
+		}
+	}
+	return ""
+}
+
+func verifyJWTSignature(key interface{}, signingInput string, sig []byte) bool {
+	sum := sha256.Sum256([]byte(signingInput))
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+	case *ecdsa.PublicKey:
+		// ES256 的签名为 32 字节的 r 和 s 直接拼接
+		if len(sig) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, sum[:], r, s)
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package wf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// signJWT 按 header 中的 alg 用 key 签名, key 为 []byte、*rsa.PrivateKey 或 *ecdsa.PrivateKey
+func signJWT(t *testing.T, header, claims map[string]interface{}, key interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	sum := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, sum[:])
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("hmac-secret")
+
+	now := time.Unix(1700000000, 0)
+	conf := &JWTConfig{
+		Key:      secret,
+		KeySet:   NewJWTKeySet(map[string]interface{}{"rsa-1": &rsaKey.PublicKey, "ec-1": &ecKey.PublicKey}),
+		Issuer:   "wf",
+		Audience: "api",
+		Leeway:   30 * time.Second,
+	}
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"sub": "geek", "iss": "wf", "aud": "api", "exp": now.Unix() + 60}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	for _, token := range []string{
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(nil), secret),
+		signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa-1"}, claims(nil), rsaKey),
+		signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec-1"}, claims(map[string]interface{}{"aud": []string{"web", "api"}}), ecKey),
+		// exp 和 nbf 在误差范围内
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": now.Unix() - 10, "nbf": now.Unix() + 10}), secret),
+	} {
+		cl, err := conf.verify(token, now)
+		require.NoError(t, err)
+		require.Equal(t, "geek", cl.Subject())
+	}
+
+	for token, expected := range map[string]error{
+		"a.b":      ErrTokenMalformed,
+		"e30.e30.": ErrTokenSignature,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(nil), []byte("other")):                                   ErrTokenSignature,
+		signJWT(t, map[string]interface{}{"alg": "none"}, claims(nil), []byte{}):                                           ErrTokenSignature,
+		signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "rsa-1"}, claims(nil), secret):                            ErrTokenSignature,
+		signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "unknown"}, claims(nil), rsaKey):                          ErrTokenUnverifiable,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": now.Unix() - 31}), secret): ErrTokenExpired,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"nbf": now.Unix() + 31}), secret): ErrTokenNotValidYet,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": "soon"}), secret):          ErrTokenMalformed,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": 1e300}), secret):           ErrTokenMalformed,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"nbf": -1e300}), secret):          ErrTokenMalformed,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"iss": "other"}), secret):         ErrTokenIssuer,
+		signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"aud": []string{"web"}}), secret): ErrTokenAudience,
+	} {
+		_, err := conf.verify(token, now)
+		require.Equal(t, expected, err, token)
+	}
+
+	// 轮换密钥: 新 kid 生效, 删除后旧 kid 失效
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	conf.KeySet.Set("ec-2", &newKey.PublicKey)
+	_, err = conf.verify(signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec-2"}, claims(nil), newKey), now)
+	require.NoError(t, err)
+	conf.KeySet.Delete("ec-1")
+	_, err = conf.verify(signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec-1"}, claims(nil), ecKey), now)
+	require.Equal(t, ErrTokenUnverifiable, err)
+
+	require.Panics(t, func() { conf.KeySet.Set("p384", "not a key") })
+	require.Panics(t, func() { JWT(JWTConfig{}) })
+	require.Panics(t, func() { JWT(JWTConfig{Key: "secret"}) })
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("hmac-secret")
+	var errs Errors
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.GET("/me", JWT(JWTConfig{Key: secret}), func(c *Context) {
+		claims := c.MustGet(JWTClaimsKey).(JWTClaims)
+		c.String(http.StatusOK, "%s %s", c.GetString(AuthUserKey), claims["role"])
+	})
+	r.GET("/query", JWT(JWTConfig{Key: secret, Query: "token"}), func(c *Context) {
+		c.String(http.StatusOK, c.GetString(AuthUserKey))
+	})
+
+	token := signJWT(t, map[string]interface{}{"alg": "HS256", "typ": "JWT"},
+		map[string]interface{}{"sub": "geek", "role": "admin", "exp": time.Now().Add(time.Minute).Unix()}, secret)
+	w := performRequest(r, "GET", "/me", withHeader("Authorization", "Bearer "+token))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "geek admin", w.Body.String())
+	require.Equal(t, "geek", performRequest(r, "GET", "/query?token="+token).Body.String())
+
+	w = performRequest(r, "GET", "/me")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	require.Empty(t, errs)
+
+	expired := signJWT(t, map[string]interface{}{"alg": "HS256"},
+		map[string]interface{}{"sub": "geek", "exp": time.Now().Add(-time.Minute).Unix()}, secret)
+	w = performRequest(r, "GET", "/me", withHeader("Authorization", "Bearer "+expired))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+	require.Len(t, errs, 1)
+	require.Equal(t, ErrTokenExpired, errs[0].Err)
+}
@@ -0,0 +1,285 @@
+package wf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogFormat int
+
+const (
+	// LogFormatDefault eg: [WF] 2006/01/02 - 15:04:05 | 200 |  1.2ms |  127.0.0.1 | GET  "/path"
+	LogFormatDefault LogFormat = iota
+	// LogFormatCommon 为 Common Log Format
+	LogFormatCommon
+	// LogFormatCombined 为 Common Log Format 加上 Referer 和 User-Agent
+	LogFormatCombined
+	// LogFormatJSON 每个请求输出一行 JSON
+	LogFormatJSON
+)
+
+const (
+	green   = "\033[97;42m"
+	white   = "\033[90;47m"
+	yellow  = "\033[90;43m"
+	red     = "\033[97;41m"
+	blue    = "\033[97;44m"
+	magenta = "\033[97;45m"
+	cyan    = "\033[97;46m"
+	reset   = "\033[0m"
+)
+
+type LoggerConfig struct {
+	Format LogFormat
+	// Formatter 不为空时覆盖 Format
+	Formatter LogFormatter
+	// Output 默认为 os.Stdout
+	Output io.Writer
+	// SkipPaths 中的路径不记录日志
+	SkipPaths []string
+}
+
+type LogFormatter func(params LogFormatterParams) string
+
+// LogFormatterParams 为 LogFormatter 的参数
+type LogFormatterParams struct {
+	Request      *http.Request
+	TimeStamp    time.Time
+	StatusCode   int
+	Latency      time.Duration
+	ClientIP     string
+	Method       string
+	Path         string
+	ErrorMessage string
+	Errors       []string
+	BodySize     int
+	Keys         map[string]interface{}
+
+	isTerm bool
+}
+
+func (p *LogFormatterParams) StatusCodeColor() string {
+	switch code := p.StatusCode; {
+	case code >= http.StatusContinue && code < http.StatusOK:
+		return white
+	case code >= http.StatusOK && code < http.StatusMultipleChoices:
+		return green
+	case code >= http.StatusMultipleChoices && code < http.StatusBadRequest:
+		return white
+	case code >= http.StatusBadRequest && code < http.StatusInternalServerError:
+		return yellow
+	default:
+		return red
+	}
+}
+
+func (p *LogFormatterParams) MethodColor() string {
+	switch p.Method {
+	case http.MethodGet:
+		return blue
+	case http.MethodPost:
+		return cyan
+	case http.MethodPut:
+		return yellow
+	case http.MethodDelete:
+		return red
+	case http.MethodPatch:
+		return green
+	case http.MethodHead:
+		return magenta
+	default:
+		return reset
+	}
+}
+
+func (p *LogFormatterParams) ResetColor() string {
+	return reset
+}
+
+// IsOutputColor 返回输出是否为终端, 只有终端才使用颜色
+func (p *LogFormatterParams) IsOutputColor() bool {
+	return p.isTerm
+}
+
+func defaultLogFormatter(p LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if p.IsOutputColor() {
+		statusColor, methodColor, resetColor = p.StatusCodeColor(), p.MethodColor(), p.ResetColor()
+	}
+	if p.Latency > time.Minute {
+		p.Latency = p.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[WF] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, p.StatusCode, resetColor,
+		p.Latency,
+		p.ClientIP,
+		methodColor, p.Method, resetColor,
+		p.Path,
+		p.ErrorMessage,
+	)
+}
+
+// commonLogFormatter 和 combinedLogFormatter 保持标准格式, 不输出错误信息
+func commonLogFormatter(p LogFormatterParams) string {
+	return commonLogLine(p) + "\n"
+}
+
+func combinedLogFormatter(p LogFormatterParams) string {
+	return fmt.Sprintf("%s %q %q\n", commonLogLine(p), orDash(p.Request.Referer()), orDash(p.Request.UserAgent()))
+}
+
+// commonLogLine 的用户优先取认证中间件保存的 AuthUserKey
+// eg: 127.0.0.1 - geek [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326
+func commonLogLine(p LogFormatterParams) string {
+	user := "-"
+	if name, ok := p.Keys[AuthUserKey].(string); ok && name != "" {
+		user = name
+	} else if p.Request.URL.User != nil && p.Request.URL.User.Username() != "" {
+		user = p.Request.URL.User.Username()
+	}
+	size := "-"
+	if p.BodySize > 0 {
+		size = fmt.Sprint(p.BodySize)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		orDash(p.ClientIP), user,
+		p.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+		p.Method, p.Path, p.Request.Proto,
+		p.StatusCode, size,
+	)
+}
+
+func jsonLogFormatter(p LogFormatterParams) string {
+	entry := struct {
+		Time      string   `json:"time"`
+		Status    int      `json:"status"`
+		Latency   float64  `json:"latency_ms"`
+		ClientIP  string   `json:"client_ip"`
+		Method    string   `json:"method"`
+		Path      string   `json:"path"`
+		Proto     string   `json:"proto"`
+		Size      int      `json:"size"`
+		Referer   string   `json:"referer,omitempty"`
+		UserAgent string   `json:"user_agent,omitempty"`
+		Errors    []string `json:"errors,omitempty"`
+	}{
+		Time:      p.TimeStamp.Format(time.RFC3339Nano),
+		Status:    p.StatusCode,
+		Latency:   float64(p.Latency) / float64(time.Millisecond),
+		ClientIP:  p.ClientIP,
+		Method:    p.Method,
+		Path:      p.Path,
+		Proto:     p.Request.Proto,
+		Size:      p.BodySize,
+		Referer:   p.Request.Referer(),
+		UserAgent: p.Request.UserAgent(),
+		Errors:    p.Errors,
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(b) + "\n"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc {
+	out := conf.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	formatter := conf.Formatter
+	if formatter == nil {
+		switch conf.Format {
+		case LogFormatCommon:
+			formatter = commonLogFormatter
+		case LogFormatCombined:
+			formatter = combinedLogFormatter
+		case LogFormatJSON:
+			formatter = jsonLogFormatter
+		default:
+			formatter = defaultLogFormatter
+		}
+	}
+
+	skip := make(map[string]struct{}, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skip[path] = struct{}{}
+	}
+
+	isTerm := isTerminal(out)
+	var mu sync.Mutex
+
+	return func(c *Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		raw := c.Request.URL.RawQuery
+
+		c.Next()
+
+		if _, ok := skip[path]; ok {
+			return
+		}
+
+		params := LogFormatterParams{
+			Request:    c.Request,
+			TimeStamp:  time.Now(),
+			StatusCode: c.Writer.Status(),
+			ClientIP:   c.ClientIP(),
+			Method:     c.Request.Method,
+			Path:       path,
+			BodySize:   c.Writer.Size(),
+			isTerm:     isTerm,
+		}
+		params.Latency = params.TimeStamp.Sub(start)
+		if raw != "" {
+			params.Path = path + "?" + raw
+		}
+		if params.BodySize < 0 {
+			params.BodySize = 0
+		}
+		if len(c.Errors) != 0 {
+			params.ErrorMessage = c.Errors.String()
+			params.Errors = c.Errors.Errors()
+		}
+		c.mu.RLock()
+		params.Keys = c.Keys
+		line := formatter(params)
+		c.mu.RUnlock()
+
+		mu.Lock()
+		_, _ = io.WriteString(out, line)
+		mu.Unlock()
+	}
+}
+
+// isTerminal 判断 w 是否为字符设备, 不依赖第三方库
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if strings.EqualFold(os.Getenv("TERM"), "dumb") {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,108 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newLoggerEngine(conf LoggerConfig) *Engine {
+	r := New()
+	r.Use(LoggerWithConfig(conf))
+	r.GET("/users/:id", func(c *Context) { c.String(http.StatusOK, "user") })
+	r.GET("/health", func(c *Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/fail", func(c *Context) {
+		c.Error(errors.New("db down"))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+	return r
+}
+
+func TestLoggerDefault(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := newLoggerEngine(LoggerConfig{Output: buf, SkipPaths: []string{"/health"}})
+
+	performRequest(r, "GET", "/users/1?x=y")
+	require.Contains(t, buf.String(), "[WF]")
+	require.Contains(t, buf.String(), "| 200 |")
+	require.Contains(t, buf.String(), `GET      "/users/1?x=y"`)
+	require.NotContains(t, buf.String(), "\033[")
+
+	buf.Reset()
+	performRequest(r, "GET", "/health")
+	require.Empty(t, buf.String())
+
+	performRequest(r, "POST", "/missing")
+	require.Contains(t, buf.String(), "| 404 |")
+
+	buf.Reset()
+	performRequest(r, "GET", "/fail")
+	require.Contains(t, buf.String(), "| 500 |")
+	require.Contains(t, buf.String(), "Error #01: db down")
+}
+
+func TestLoggerCommon(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := newLoggerEngine(LoggerConfig{Output: buf, Format: LogFormatCommon})
+	performRequest(r, "GET", "/users/1")
+	require.Regexp(t, regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /users/1 HTTP/1\.1" 200 4\n$`), buf.String())
+
+	buf.Reset()
+	r = newLoggerEngine(LoggerConfig{Output: buf, Format: LogFormatCombined})
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	require.True(t, strings.HasSuffix(buf.String(), `200 4 "-" "test-agent"`+"\n"), buf.String())
+}
+
+func TestLoggerJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := newLoggerEngine(LoggerConfig{Output: buf, Format: LogFormatJSON})
+	performRequest(r, "GET", "/fail")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, float64(500), entry["status"])
+	require.Equal(t, "/fail", entry["path"])
+	require.Equal(t, "192.0.2.1", entry["client_ip"])
+	require.Equal(t, []interface{}{"db down"}, entry["errors"])
+}
+
+func TestLoggerFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := newLoggerEngine(LoggerConfig{Output: buf, Formatter: func(p LogFormatterParams) string {
+		return p.Method + " " + p.Path + " " + http.StatusText(p.StatusCode) + "\n"
+	}})
+	performRequest(r, "GET", "/users/2")
+	require.Equal(t, "GET /users/2 OK\n", buf.String())
+}
+
+func TestClientIP(t *testing.T) {
+	r := New()
+	r.GET("/ip", func(c *Context) { c.String(http.StatusOK, c.ClientIP()) })
+	get := func(remote, forwarded string) string {
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	require.Equal(t, "10.0.0.1", get("10.0.0.1:1234", "1.1.1.1"))
+
+	require.NoError(t, r.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}))
+	require.Equal(t, "1.1.1.1", get("10.0.0.1:1234", "1.1.1.1"))
+	require.Equal(t, "2.2.2.2", get("10.0.0.1:1234", "1.1.1.1, 2.2.2.2, 192.168.1.1"))
+	require.Equal(t, "10.0.0.1", get("10.0.0.1:1234", "bogus"))
+	require.Equal(t, "3.3.3.3", get("3.3.3.3:1234", "1.1.1.1"))
+
+	require.Error(t, r.SetTrustedProxies([]string{"not-an-ip"}))
+}
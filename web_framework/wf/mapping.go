@@ -0,0 +1,289 @@
+package wf
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// valueSource 按名称查找待绑定的值
+type valueSource interface {
+	lookup(name string) ([]string, bool)
+}
+
+type formSource map[string][]string
+
+func (s formSource) lookup(name string) ([]string, bool) {
+	vs, ok := s[name]
+	return vs, ok
+}
+
+// headerSource 查找时规范化名称, 使 tag 中的 header 名称不区分大小写
+type headerSource http.Header
+
+func (s headerSource) lookup(name string) ([]string, bool) {
+	vs, ok := s[textproto.CanonicalMIMEHeaderKey(name)]
+	return vs, ok
+}
+
+// mapping 按 tag 将 values 绑定到 ptr 指向的结构体上
+// tag 格式为 `form:"name,default=value"`, 切片的默认值用 ';' 分隔
+// 未指定名称的嵌套结构体会被展开, 指针字段只有在有值时才会分配, 递归引用自身的结构体只展开一层
+func mapping(ptr interface{}, values valueSource, tag, source string) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return &BindError{Source: source, Err: errors.New("target must be a non-nil pointer to struct")}
+	}
+	_, err := mapStruct(v.Elem(), values, tag, source, "", map[reflect.Type]bool{})
+	return err
+}
+
+// mapStruct 返回是否有字段被设置, visiting 为当前递归路径上的结构体类型
+func mapStruct(v reflect.Value, values valueSource, tag, source, path string, visiting map[reflect.Type]bool) (bool, error) {
+	t := v.Type()
+	if visiting[t] {
+		return false, nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	set := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		fieldPath := sf.Name
+		if path != "" {
+			fieldPath = path + "." + sf.Name
+		}
+		ok, err := mapField(v.Field(i), sf, values, tag, source, fieldPath, visiting)
+		if err != nil {
+			return set, err
+		}
+		set = set || ok
+	}
+	return set, nil
+}
+
+func mapField(field reflect.Value, sf reflect.StructField, values valueSource, tag, source, path string, visiting map[reflect.Type]bool) (bool, error) {
+	tagValue := sf.Tag.Get(tag)
+	if tagValue == "-" {
+		return false, nil
+	}
+	name, defaultValue, hasDefault := parseBindingTag(tagValue)
+
+	if field.Kind() == reflect.Ptr && !isScalarType(field.Type()) {
+		elem := reflect.New(field.Type().Elem())
+		ok, err := mapField(elem.Elem(), sf, values, tag, source, path, visiting)
+		if ok && field.CanSet() {
+			field.Set(elem)
+		}
+		return ok, err
+	}
+	if field.Kind() == reflect.Struct && name == "" && !isScalarType(field.Type()) {
+		return mapStruct(field, values, tag, source, path, visiting)
+	}
+	if !field.CanSet() {
+		return false, nil
+	}
+
+	if name == "" {
+		name = sf.Name
+	}
+	vs, ok := values.lookup(name)
+	if !ok || len(vs) == 0 {
+		if !hasDefault {
+			return false, nil
+		}
+		vs = []string{defaultValue}
+		if k := field.Kind(); k == reflect.Slice || k == reflect.Array {
+			vs = strings.Split(defaultValue, ";")
+		}
+	}
+
+	if err := setValues(field, sf, vs); err != nil {
+		return false, &BindError{Source: source, Field: path, Err: err}
+	}
+	return true, nil
+}
+
+func parseBindingTag(tag string) (name, defaultValue string, hasDefault bool) {
+	opts := strings.Split(tag, ",")
+	name = opts[0]
+	for _, opt := range opts[1:] {
+		if strings.HasPrefix(opt, "default=") {
+			defaultValue, hasDefault = opt[len("default="):], true
+		}
+	}
+	return
+}
+
+// isScalarType 判断结构体类型是否作为单个值解析, 如 time.Time
+func isScalarType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	return t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func setValues(field reflect.Value, sf reflect.StructField, vs []string) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 && !isTextUnmarshaler(field) {
+			field.SetBytes([]byte(vs[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(field.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(vs) != field.Len() {
+			return fmt.Errorf("%q is not valid value for %s", vs, field.Type())
+		}
+		for i, s := range vs {
+			if err := setValue(field.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setValue(field, sf, vs[0])
+}
+
+func isTextUnmarshaler(v reflect.Value) bool {
+	return v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType)
+}
+
+func setValue(v reflect.Value, sf reflect.StructField, s string) error {
+	switch v.Type() {
+	case timeType:
+		return setTime(v, sf, s)
+	case durationType:
+		if s == "" {
+			s = "0"
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), sf, s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if isTextUnmarshaler(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err == nil {
+			v.SetBool(b)
+		}
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err == nil {
+			v.SetInt(n)
+		}
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err == nil {
+			v.SetUint(n)
+		}
+		return err
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err == nil {
+			v.SetFloat(f)
+		}
+		return err
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// setTime 支持 time_format(默认 RFC3339, 或 unix/unixmilli/unixnano) 和 time_location tag
+func setTime(v reflect.Value, sf reflect.StructField, s string) error {
+	if s == "" {
+		v.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+
+	layout := sf.Tag.Get("time_format")
+	switch layout {
+	case "unix", "unixmilli", "unixnano":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		t := time.Unix(n, 0)
+		if layout == "unixmilli" {
+			t = time.Unix(n/1e3, n%1e3*1e6)
+		} else if layout == "unixnano" {
+			t = time.Unix(0, n)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case "":
+		layout = time.RFC3339
+	}
+
+	loc := time.Local
+	if name := sf.Tag.Get("time_location"); name != "" {
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		loc = l
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
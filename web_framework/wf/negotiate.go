@@ -0,0 +1,169 @@
+package wf
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Negotiate 为 Context.Negotiate 的参数
+// 选中格式对应的 XXXData 为 nil 时使用 Data
+type Negotiate struct {
+	// Offered 为服务端支持的 MIME 类型, 按优先级从高到低排列
+	Offered  []string
+	HTMLName string
+	HTMLData interface{}
+	JSONData interface{}
+	XMLData  interface{}
+	Data     interface{}
+}
+
+func defaultRenders() map[string]RenderFunc {
+	xmlRender := func(data interface{}) Render { return XMLRender{Data: data} }
+	return map[string]RenderFunc{
+		"application/json": func(data interface{}) Render { return JSONRender{Data: data} },
+		"application/xml":  xmlRender,
+		"text/xml":         xmlRender,
+		"text/plain": func(data interface{}) Render {
+			return StringRender{Format: "%v", Data: []interface{}{data}}
+		},
+	}
+}
+
+// RegisterRender 注册 Negotiate 使用的 Render, 可以覆盖内置的 JSON、XML 和纯文本格式
+func (engine *Engine) RegisterRender(contentType string, fn RenderFunc) {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if contentType == "" || fn == nil {
+		panic("Invalid render for content type: " + contentType)
+	}
+	engine.renders[contentType] = fn
+}
+
+// Negotiate 根据 Accept 头从 config.Offered 中选出格式并渲染, 没有可接受的格式时返回 406
+// 选中的格式没有注册 Render 时返回 500, 错误记录在 c.Errors 中
+func (c *Context) Negotiate(code int, config Negotiate) {
+	c.Writer.Header().Add("Vary", "Accept")
+	format := c.NegotiateFormat(config.Offered...)
+	if format == "" {
+		c.AbortWithError(http.StatusNotAcceptable, fmt.Errorf("no offered format is acceptable: %q", c.GetHeader("Accept"))).
+			SetType(ErrorTypePublic)
+		return
+	}
+
+	pick := func(data interface{}) interface{} {
+		if data == nil {
+			return config.Data
+		}
+		return data
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(format, ";", 2)[0]))
+	switch mediaType {
+	case "text/html":
+		c.HTML(code, config.HTMLName, pick(config.HTMLData))
+		return
+	case "application/json":
+		config.Data = pick(config.JSONData)
+	case "application/xml", "text/xml":
+		config.Data = pick(config.XMLData)
+	}
+	fn, ok := c.engine.renders[mediaType]
+	if !ok {
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("undefined render for content type %q", format))
+		return
+	}
+	c.Render(code, fn(config.Data))
+}
+
+// NegotiateFormat 返回 offered 中 Accept 头 q 值最高的类型, q 值相同时按 offered 的顺序
+// 没有 Accept 头时返回 offered[0], 都不可接受时返回空字符串
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, format := range offered {
+		if q := acceptQuality(ranges, format); q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+// parseAccept 解析 Accept 头, 忽略格式错误的条目和 q 以外的参数
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, sub, ok := splitMediaType(params[0])
+		if !ok {
+			continue
+		}
+		r := acceptRange{typ: typ, sub: sub, q: 1}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			if !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				ok = false
+				break
+			}
+			r.q = q
+		}
+		if ok {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// acceptQuality 返回与 format 匹配的最具体的范围的 q 值
+func acceptQuality(ranges []acceptRange, format string) float64 {
+	typ, sub, ok := splitMediaType(strings.SplitN(format, ";", 2)[0])
+	if !ok {
+		return 0
+	}
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.sub == sub:
+			s = 2
+		case r.typ == typ && r.sub == "*":
+			s = 1
+		case r.typ == "*" && r.sub == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+func splitMediaType(s string) (typ, sub string, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "*" {
+		return "*", "*", true
+	}
+	typ, sub, ok = strings.Cut(s, "/")
+	if !ok || typ == "" || sub == "" {
+		return "", "", false
+	}
+	return typ, sub, true
+}
@@ -0,0 +1,240 @@
+package wf
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitAlgorithm 为限流算法
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket 以 Limit/Period 的速率补充令牌, 最多积累 Burst 个, 允许短时突发
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow 按上一个窗口的计数加权估算最近一个 Period 内的请求数
+	SlidingWindow
+)
+
+var errRateLimitAlgorithm = errors.New("rate limit algorithm is not supported by store")
+
+// Rate 描述一个限流规则, 由中间件传给 RateLimitStore
+type Rate struct {
+	Algorithm RateLimitAlgorithm
+	// Limit 为每个 Period 允许的请求数
+	Limit  int
+	Period time.Duration
+	// Burst 为令牌桶的容量, 只用于 TokenBucket
+	Burst int
+}
+
+// RateLimitResult 为一次 Take 的结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset 为配额恢复需要的时间, TokenBucket 为令牌桶补满的时间, SlidingWindow 为当前窗口结束的时间
+	Reset time.Duration
+	// RetryAfter 为被拒绝时距离下一个请求可以通过的时间
+	RetryAfter time.Duration
+}
+
+// RateLimitStore 保存限流状态, Take 为 key 消耗一次配额, 需要并发安全
+type RateLimitStore interface {
+	// Supports 返回是否支持 algorithm, RateLimit 创建时检查
+	Supports(algorithm RateLimitAlgorithm) bool
+	// Take 无法得出结果时返回零值和错误; 已得出结果但后续操作失败时同时返回结果和错误
+	Take(key string, rate Rate) (RateLimitResult, error)
+}
+
+// RateLimitConfig 配置限流中间件
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+	// Limit 为每个 Period 允许的请求数
+	Limit  int
+	Period time.Duration
+	// Burst 为令牌桶的容量, 默认等于 Limit
+	Burst int
+	// Key 返回限流的键, 默认为 KeyByIP, 返回空字符串时不限流
+	Key func(c *Context) string
+	// Store 默认为进程内的 MemoryRateLimitStore
+	Store RateLimitStore
+	// Prefix 用于区分共用一个 Store 的多个限流中间件, 默认按创建顺序生成
+	// 多个实例通过 GroupRateLimitStore 共享计数时, 需要以相同顺序创建中间件或显式设置
+	Prefix string
+	// LimitReached 处理被限流的请求, 默认返回 429
+	LimitReached HandlerFunc
+}
+
+var rateLimitSeq int64
+
+// RateLimit 返回限流中间件, 可以用于 Engine、分组或单个路由
+// 响应带有 RateLimit-Limit、RateLimit-Remaining 和 RateLimit-Reset 头, 被限流时还有 Retry-After
+// Store 不支持 Algorithm 时 panic, Store 出错且没有结果时拒绝请求并返回 500, 有结果时按结果处理并记录错误
+func RateLimit(conf RateLimitConfig) HandlerFunc {
+	if conf.Limit <= 0 || conf.Period <= 0 {
+		panic("Invalid rate limit: limit and period must be positive")
+	}
+	if conf.Algorithm != TokenBucket && conf.Algorithm != SlidingWindow {
+		panic("Invalid rate limit algorithm: " + strconv.Itoa(int(conf.Algorithm)))
+	}
+	if conf.Burst <= 0 {
+		conf.Burst = conf.Limit
+	}
+	if conf.Key == nil {
+		conf.Key = KeyByIP()
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryRateLimitStore(0)
+	}
+	if !conf.Store.Supports(conf.Algorithm) {
+		panic("Rate limit algorithm is not supported by store: " + strconv.Itoa(int(conf.Algorithm)))
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = "ratelimit:" + strconv.FormatInt(atomic.AddInt64(&rateLimitSeq, 1), 10) + ":"
+	}
+	if conf.LimitReached == nil {
+		conf.LimitReached = func(c *Context) { c.AbortWithStatus(http.StatusTooManyRequests) }
+	}
+	rate := Rate{Algorithm: conf.Algorithm, Limit: conf.Limit, Period: conf.Period, Burst: conf.Burst}
+	policy := strconv.Itoa(conf.Limit) + ";w=" + strconv.FormatInt(int64(ceilSeconds(conf.Period)), 10)
+
+	return func(c *Context) {
+		key := conf.Key(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res, err := conf.Store.Take(conf.Prefix+key, rate)
+		if err != nil {
+			if res == (RateLimitResult{}) {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			c.Error(err)
+		}
+
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", policy)
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			conf.LimitReached(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// KeyByIP 按 ClientIP 限流
+func KeyByIP() func(c *Context) string {
+	return func(c *Context) string { return "ip:" + c.ClientIP() }
+}
+
+// KeyByHeader 按请求头限流, 请求头为空时按 ClientIP
+func KeyByHeader(name string) func(c *Context) string {
+	return func(c *Context) string {
+		if v := c.GetHeader(name); v != "" {
+			return "header:" + v
+		}
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// KeyByParam 按路由参数限流, 参数为空时按 ClientIP
+func KeyByParam(name string) func(c *Context) string {
+	return func(c *Context) string {
+		if v := c.Param(name); v != "" {
+			return "param:" + v
+		}
+		return "ip:" + c.ClientIP()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// tokenBucket 为令牌桶的状态, tokens 为 last 时刻的令牌数
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(rate Rate, now time.Time) RateLimitResult {
+	perSecond := float64(rate.Limit) / rate.Period.Seconds()
+	capacity := float64(rate.Burst)
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*perSecond)
+	}
+	b.last = now
+
+	res := RateLimitResult{Limit: rate.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsDuration((1 - b.tokens) / perSecond)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = secondsDuration((capacity - b.tokens) / perSecond)
+	return res
+}
+
+// full 返回令牌桶在 now 时是否已满, 满的桶与没有记录等价
+func (b *tokenBucket) full(rate Rate, now time.Time) bool {
+	perSecond := float64(rate.Limit) / rate.Period.Seconds()
+	return b.tokens+now.Sub(b.last).Seconds()*perSecond >= float64(rate.Burst)
+}
+
+// slidingWindow 根据上一个窗口和当前窗口的计数判断请求能否通过
+// curr 中包含本次请求, elapsed 为当前窗口已经过去的时间
+func slidingWindow(rate Rate, prev, curr int64, elapsed time.Duration) RateLimitResult {
+	limit := int64(rate.Limit)
+	weight := 1 - float64(elapsed)/float64(rate.Period)
+	estimate := float64(prev)*weight + float64(curr)
+	res := RateLimitResult{Limit: rate.Limit, Reset: rate.Period - elapsed}
+	if estimate <= float64(limit) {
+		res.Allowed = true
+		res.Remaining = int(float64(limit) - estimate)
+		return res
+	}
+
+	// 本次请求不计入, 求出估算值降到 limit-1 以下的时间
+	curr--
+	if free := limit - 1 - curr; free >= 0 && prev > 0 {
+		res.RetryAfter = ceilDuration(float64(rate.Period)*(1-float64(free)/float64(prev))) - elapsed
+	} else {
+		res.RetryAfter = rate.Period - elapsed
+		if curr > 0 {
+			res.RetryAfter += ceilDuration(float64(rate.Period) * (1 - float64(limit-1)/float64(curr)))
+		}
+	}
+	if res.RetryAfter < 0 {
+		res.RetryAfter = 0
+	}
+	if res.RetryAfter > res.Reset {
+		res.Reset = res.RetryAfter
+	}
+	return res
+}
+
+func secondsDuration(s float64) time.Duration {
+	return ceilDuration(s * float64(time.Second))
+}
+
+// ceilDuration 向上取整, 避免浮点误差使 RetryAfter 偏小
+func ceilDuration(ns float64) time.Duration {
+	return time.Duration(math.Ceil(ns))
+}
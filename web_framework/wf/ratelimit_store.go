@@ -0,0 +1,186 @@
+package wf
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"dc"
+)
+
+const defaultRateLimitShards = 32
+
+// MemoryRateLimitStore 将限流状态保存在进程内存中, 按键的哈希分片加锁以减少竞争
+// 已恢复到初始状态的记录在每个分片每分钟一次的清理中删除
+type MemoryRateLimitStore struct {
+	shards []*rateLimitShard
+	now    func() time.Time
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+type rateLimitEntry struct {
+	rate   Rate
+	bucket tokenBucket
+	// window 为当前窗口的序号, prev 和 curr 为上一个和当前窗口的计数
+	window     int64
+	prev, curr int64
+}
+
+// NewMemoryRateLimitStore 的 shards 小于等于 0 时使用 32 个分片
+func NewMemoryRateLimitStore(shards int) *MemoryRateLimitStore {
+	if shards <= 0 {
+		shards = defaultRateLimitShards
+	}
+	st := &MemoryRateLimitStore{shards: make([]*rateLimitShard, shards), now: time.Now}
+	for i := range st.shards {
+		st.shards[i] = &rateLimitShard{entries: make(map[string]*rateLimitEntry)}
+	}
+	return st
+}
+
+func (st *MemoryRateLimitStore) Supports(algorithm RateLimitAlgorithm) bool {
+	return algorithm == TokenBucket || algorithm == SlidingWindow
+}
+
+func (st *MemoryRateLimitStore) Take(key string, rate Rate) (RateLimitResult, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := st.shards[h.Sum32()%uint32(len(st.shards))]
+	now := st.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok || entry.rate != rate {
+		entry = &rateLimitEntry{rate: rate}
+		shard.entries[key] = entry
+	}
+	var res RateLimitResult
+	if rate.Algorithm == TokenBucket {
+		res = entry.bucket.take(rate, now)
+	} else {
+		window, elapsed := slidingWindowIndex(rate.Period, now)
+		entry.advance(window)
+		res = slidingWindow(rate, entry.prev, entry.curr+1, elapsed)
+		if res.Allowed {
+			entry.curr++
+		}
+	}
+	shard.sweep(now)
+	return res, nil
+}
+
+// advance 将计数移动到 window 所在的窗口
+func (e *rateLimitEntry) advance(window int64) {
+	switch window - e.window {
+	case 0:
+	case 1:
+		e.prev, e.curr = e.curr, 0
+	default:
+		e.prev, e.curr = 0, 0
+	}
+	e.window = window
+}
+
+// idle 返回记录在 now 时是否已经不影响限流结果
+func (e *rateLimitEntry) idle(now time.Time) bool {
+	if e.rate.Algorithm == TokenBucket {
+		return e.bucket.full(e.rate, now)
+	}
+	window, _ := slidingWindowIndex(e.rate.Period, now)
+	return window-e.window > 1
+}
+
+// sweep 至多每分钟删除一次空闲的记录, 调用者需持有 mu
+func (s *rateLimitShard) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if entry.idle(now) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// slidingWindowIndex 返回 now 所在窗口的序号和窗口内已经过去的时间, 各实例的窗口边界一致
+func slidingWindowIndex(period time.Duration, now time.Time) (int64, time.Duration) {
+	ns := now.UnixNano()
+	return ns / int64(period), time.Duration(ns % int64(period))
+}
+
+// RateLimitCounter 为 GroupRateLimitStore 提供原子计数的共享存储, 例如 Redis 的 INCRBY
+// 所有写入都经过它, 读取当前窗口时也直接访问它, delta 为 0 时只读取
+// dc.Group 的 Getter 应从同一个存储中按键读取计数, 返回十进制字符串
+type RateLimitCounter interface {
+	// Incr 将 key 的计数加上 delta 并返回新值, ttl 后 key 可以被删除
+	Incr(key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+const defaultRateLimitClockSkew = time.Second
+
+// GroupRateLimitStore 让多个实例共享滑动窗口的计数, 只支持 SlidingWindow,
+// 令牌桶需要对同一个状态读写, 无法在只缓存不失效的 dc.Group 上实现
+// 计数本身保存在 counter 中, 每个请求都会访问一次 counter; dc.Group 只用于读取上一个窗口的计数,
+// 它在窗口结束后不再变化, 缓存后各节点不必再访问 counter
+// 各实例时钟不一致时, 时钟落后的实例在窗口结束后仍会写入上一个窗口, 所以窗口开始的 MaxClockSkew 内
+// 直接从 counter 读取上一个窗口; 时钟误差超过 MaxClockSkew 时, 晚到的计数不会被已缓存的节点看到
+type GroupRateLimitStore struct {
+	// MaxClockSkew 为各实例间允许的最大时钟误差, 默认为 1 秒
+	MaxClockSkew time.Duration
+
+	group   *dc.Group
+	counter RateLimitCounter
+	now     func() time.Time
+}
+
+func NewGroupRateLimitStore(group *dc.Group, counter RateLimitCounter) *GroupRateLimitStore {
+	if group == nil || counter == nil {
+		panic("Group and counter are required for GroupRateLimitStore")
+	}
+	return &GroupRateLimitStore{MaxClockSkew: defaultRateLimitClockSkew, group: group, counter: counter, now: time.Now}
+}
+
+func (st *GroupRateLimitStore) Supports(algorithm RateLimitAlgorithm) bool {
+	return algorithm == SlidingWindow
+}
+
+func (st *GroupRateLimitStore) Take(key string, rate Rate) (RateLimitResult, error) {
+	if !st.Supports(rate.Algorithm) {
+		return RateLimitResult{}, errRateLimitAlgorithm
+	}
+	window, elapsed := slidingWindowIndex(rate.Period, st.now())
+	currKey := key + "|" + strconv.FormatInt(window, 10)
+	curr, err := st.counter.Incr(currKey, 1, 2*rate.Period)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	prevKey := key + "|" + strconv.FormatInt(window-1, 10)
+	var prev int64
+	if elapsed < st.MaxClockSkew {
+		// 时钟落后的实例可能还在写入上一个窗口, 不能缓存
+		if prev, err = st.counter.Incr(prevKey, 0, 2*rate.Period); err != nil {
+			return RateLimitResult{}, err
+		}
+	} else if view, err := st.group.Get(prevKey); err == nil {
+		// 上一个窗口没有请求时 Getter 可能返回错误, 按 0 处理
+		prev, _ = strconv.ParseInt(view.String(), 10, 64)
+	}
+
+	res := slidingWindow(rate, prev, curr, elapsed)
+	if !res.Allowed {
+		// 被拒绝的请求不占用配额, 撤销失败只会让计数偏大, 结果仍然有效, 错误交给中间件记录
+		if _, err = st.counter.Incr(currKey, -1, 2*rate.Period); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
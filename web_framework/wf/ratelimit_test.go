@@ -0,0 +1,316 @@
+package wf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"dc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	rate := Rate{Algorithm: TokenBucket, Limit: 2, Period: time.Second, Burst: 3}
+	now := time.Unix(1000, 0)
+	var b tokenBucket
+	for i := 2; i >= 0; i-- {
+		res := b.take(rate, now)
+		require.True(t, res.Allowed)
+		require.Equal(t, i, res.Remaining)
+	}
+	res := b.take(rate, now)
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	require.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	res = b.take(rate, now.Add(500*time.Millisecond))
+	require.True(t, res.Allowed)
+	require.False(t, b.full(rate, now.Add(time.Second)))
+	require.True(t, b.full(rate, now.Add(2*time.Second)))
+}
+
+func TestSlidingWindow(t *testing.T) {
+	rate := Rate{Algorithm: SlidingWindow, Limit: 10, Period: 10 * time.Second}
+	res := slidingWindow(rate, 0, 10, 2*time.Second)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.Equal(t, 8*time.Second, res.Reset)
+
+	// 上一个窗口的权重为 0.5, 估算值为 5 + 6
+	res = slidingWindow(rate, 10, 6, 5*time.Second)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Second, res.RetryAfter)
+
+	// 当前窗口已满, 需要等到下一个窗口中当前窗口的权重下降
+	res = slidingWindow(rate, 0, 11, 5*time.Second)
+	require.False(t, res.Allowed)
+	require.Equal(t, 6*time.Second, res.RetryAfter)
+	require.Equal(t, 6*time.Second, res.Reset)
+}
+
+func TestRateLimit(t *testing.T) {
+	store := NewMemoryRateLimitStore(1)
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+
+	r := New()
+	api := r.Group("/api")
+	api.Use(RateLimit(RateLimitConfig{Limit: 2, Period: time.Minute, Store: store}))
+	api.GET("/a", func(c *Context) { c.String(http.StatusOK, "a") })
+	api.GET("/b", func(c *Context) { c.String(http.StatusOK, "b") })
+	r.GET("/users/:id", RateLimit(RateLimitConfig{
+		Algorithm: SlidingWindow,
+		Limit:     1,
+		Period:    10 * time.Second,
+		Key:       KeyByParam("id"),
+		Store:     store,
+	}), func(c *Context) { c.String(http.StatusOK, c.Param("id")) })
+	r.GET("/free", func(c *Context) { c.String(http.StatusOK, "free") })
+
+	w := performRequest(r, "GET", "/api/a")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	require.Empty(t, w.Header().Get("Retry-After"))
+
+	// 分组内的路由共享配额
+	require.Equal(t, http.StatusOK, performRequest(r, "GET", "/api/b").Code)
+	w = performRequest(r, "GET", "/api/a")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.Equal(t, "free", performRequest(r, "GET", "/free").Body.String())
+
+	now = now.Add(30 * time.Second)
+	require.Equal(t, http.StatusOK, performRequest(r, "GET", "/api/a").Code)
+
+	// 单个路由按参数限流
+	require.Equal(t, http.StatusOK, performRequest(r, "GET", "/users/1").Code)
+	require.Equal(t, http.StatusOK, performRequest(r, "GET", "/users/2").Code)
+	w = performRequest(r, "GET", "/users/1")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	// 上一个窗口的请求在下一个窗口中仍按权重计入
+	require.Equal(t, "20", w.Header().Get("Retry-After"))
+
+	// 空闲的记录在清理时删除
+	now = now.Add(time.Hour)
+	performRequest(r, "GET", "/users/3")
+	count := 0
+	for _, shard := range store.shards {
+		count += len(shard.entries)
+	}
+	require.Equal(t, 1, count)
+
+	require.Panics(t, func() { RateLimit(RateLimitConfig{Period: time.Second}) })
+	require.Panics(t, func() { RateLimit(RateLimitConfig{Limit: 1, Period: time.Second, Algorithm: 5}) })
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	var handled int
+	r := New()
+	r.Use(RateLimit(RateLimitConfig{
+		Limit:        1,
+		Period:       time.Minute,
+		Key:          KeyByHeader("X-API-Key"),
+		LimitReached: func(c *Context) { handled++; c.String(http.StatusTooManyRequests, "slow down") },
+	}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	request := func(apiKey, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	require.Equal(t, http.StatusOK, request("k1", "10.0.0.1").Code)
+	require.Equal(t, http.StatusOK, request("k2", "10.0.0.1").Code)
+	w := request("k1", "10.0.0.2")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "slow down", w.Body.String())
+	require.Equal(t, 1, handled)
+	require.Equal(t, http.StatusOK, request("", "10.0.0.1").Code)
+	require.Equal(t, http.StatusOK, request("", "10.0.0.2").Code)
+	require.Equal(t, http.StatusTooManyRequests, request("", "10.0.0.1").Code)
+
+	// Key 返回空字符串时不限流, Store 出错时拒绝请求并记录错误
+	var errs Errors
+	r = New()
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.GET("/skip", RateLimit(RateLimitConfig{Limit: 1, Period: time.Minute, Key: func(c *Context) string { return "" }}),
+		func(c *Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/broken", RateLimit(RateLimitConfig{Limit: 1, Period: time.Minute, Algorithm: SlidingWindow, Store: &mapCounter{}}),
+		func(c *Context) { c.String(http.StatusOK, "ok") })
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, performRequest(r, "GET", "/skip").Code)
+	}
+	w = performRequest(r, "GET", "/broken")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Len(t, errs, 1)
+	require.Equal(t, errMapCounter, errs[0].Err)
+
+	// Store 同时返回结果和错误时按结果处理
+	r.GET("/partial", RateLimit(RateLimitConfig{Limit: 1, Period: time.Minute, Store: partialStore{}}),
+		func(c *Context) { c.String(http.StatusOK, "ok") })
+	w = performRequest(r, "GET", "/partial")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+	require.Len(t, errs, 1)
+	require.Equal(t, errMapCounter, errs[0].Err)
+
+	// Store 不支持的算法在创建时 panic
+	group := dc.NewGroup(fmt.Sprintf("ratelimit-tb-%d", time.Now().UnixNano()), 1<<20, dc.GetterFunc(func(string) ([]byte, error) { return nil, nil }))
+	require.Panics(t, func() {
+		RateLimit(RateLimitConfig{Limit: 1, Period: time.Minute, Store: NewGroupRateLimitStore(group, &mapCounter{})})
+	})
+}
+
+var errMapCounter = errors.New("counter is unavailable")
+
+// partialStore 总是拒绝请求, 并报告撤销计数失败
+type partialStore struct{}
+
+func (partialStore) Supports(RateLimitAlgorithm) bool {
+	return true
+}
+
+func (partialStore) Take(string, Rate) (RateLimitResult, error) {
+	return RateLimitResult{Limit: 1, Reset: time.Second, RetryAfter: time.Second}, errMapCounter
+}
+
+// mapCounter 模拟多个实例共享的计数存储, 同时作为 Store 时总是返回错误
+type mapCounter struct {
+	mu       sync.Mutex
+	data     map[string]int64
+	failDecr bool
+}
+
+func (m *mapCounter) Supports(RateLimitAlgorithm) bool {
+	return true
+}
+
+func (m *mapCounter) Take(string, Rate) (RateLimitResult, error) {
+	return RateLimitResult{}, errMapCounter
+}
+
+func (m *mapCounter) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if delta < 0 && m.failDecr {
+		return 0, errMapCounter
+	}
+	m.data[key] += delta
+	return m.data[key], nil
+}
+
+func (m *mapCounter) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.data[key]; ok {
+		return []byte(strconv.FormatInt(v, 10)), nil
+	}
+	return nil, errors.New("counter not found")
+}
+
+func TestGroupRateLimitStore(t *testing.T) {
+	counter := &mapCounter{data: make(map[string]int64)}
+	group := dc.NewGroup(fmt.Sprintf("ratelimit-%d", time.Now().UnixNano()), 1<<20, dc.GetterFunc(counter.Get))
+	now := time.Unix(1000, 0)
+	// 两个实例共用计数
+	stores := []*GroupRateLimitStore{NewGroupRateLimitStore(group, counter), NewGroupRateLimitStore(group, counter)}
+	for _, st := range stores {
+		st.now = func() time.Time { return now }
+	}
+	rate := Rate{Algorithm: SlidingWindow, Limit: 4, Period: 10 * time.Second}
+
+	for i := 0; i < 4; i++ {
+		res, err := stores[i%2].Take("k", rate)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 3-i, res.Remaining)
+	}
+	res, err := stores[0].Take("k", rate)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, int64(4), counter.data["k|100"])
+
+	// 下一个窗口过半时, 上一个窗口的 4 次请求按一半计算
+	now = now.Add(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		res, err = stores[1].Take("k", rate)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+	res, err = stores[0].Take("k", rate)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 2500*time.Millisecond, res.RetryAfter)
+
+	// 撤销失败时仍返回拒绝的结果
+	counter.failDecr = true
+	res, err = stores[0].Take("k", rate)
+	require.Equal(t, errMapCounter, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 4, res.Limit)
+
+	_, err = stores[0].Take("k", Rate{Algorithm: TokenBucket, Limit: 1, Period: time.Second, Burst: 1})
+	require.Equal(t, errRateLimitAlgorithm, err)
+	require.Panics(t, func() { NewGroupRateLimitStore(nil, counter) })
+}
+
+func TestGroupRateLimitStoreClockSkew(t *testing.T) {
+	counter := &mapCounter{data: make(map[string]int64)}
+	group := dc.NewGroup(fmt.Sprintf("ratelimit-skew-%d", time.Now().UnixNano()), 1<<20, dc.GetterFunc(counter.Get))
+	rate := Rate{Algorithm: SlidingWindow, Limit: 10, Period: 10 * time.Second}
+	// fast 的时钟比 slow 快 500ms
+	slowNow := time.Unix(1009, 0)
+	fast, slow := NewGroupRateLimitStore(group, counter), NewGroupRateLimitStore(group, counter)
+	fast.now = func() time.Time { return slowNow.Add(500 * time.Millisecond) }
+	slow.now = func() time.Time { return slowNow }
+
+	for i := 0; i < 5; i++ {
+		_, err := slow.Take("k", rate)
+		require.NoError(t, err)
+	}
+	// fast 已进入下一个窗口, slow 仍在向上一个窗口写入: 10 - (5*0.97 + 1) = 4.15
+	slowNow = slowNow.Add(800 * time.Millisecond)
+	res, err := fast.Take("k", rate)
+	require.NoError(t, err)
+	require.Equal(t, 4, res.Remaining)
+	for i := 0; i < 3; i++ {
+		_, err = slow.Take("k", rate)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(8), counter.data["k|100"])
+
+	// 窗口开始的 MaxClockSkew 内读到最新计数: 10 - (8*0.97 + 2) = 0.24, 按缓存的 5 计算会剩 3
+	res, err = fast.Take("k", rate)
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Remaining)
+
+	// 之后通过 dc.Group 读取并缓存已经稳定的计数: 10 - (8*0.77 + 3) = 0.84
+	slowNow = slowNow.Add(2 * time.Second)
+	res, err = fast.Take("k", rate)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	// 缓存的值不再从 counter 读取: 8*0.82 + 4 > 10
+	counter.data["k|100"] = 0
+	res, err = slow.Take("k", rate)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+}
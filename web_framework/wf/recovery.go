@@ -15,7 +15,8 @@ func Recovery() HandlerFunc {
 			if err != nil {
 				log.Printf("%s\n\n", trace(fmt.Sprintf("%s", err)))
 				// log.Printf("%s\n\n", trace(err.(string)))
-				c.JSON(http.StatusInternalServerError, H{"error": "Internal Server Error"})
+				c.Error(fmt.Errorf("panic recovered: %v", err))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, H{"error": "Internal Server Error"})
 			}
 		}()
 
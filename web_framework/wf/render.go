@@ -0,0 +1,277 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Render 将数据写入响应, WriteContentType 在不允许有 body 的状态码下也会被调用
+type Render interface {
+	Render(w http.ResponseWriter) error
+	WriteContentType(w http.ResponseWriter)
+}
+
+// RenderFunc 根据 Negotiate 选出的数据创建 Render, 通过 Engine.RegisterRender 注册
+type RenderFunc func(data interface{}) Render
+
+var (
+	_ Render = JSONRender{}
+	_ Render = IndentedJSONRender{}
+	_ Render = SecureJSONRender{}
+	_ Render = JSONPRender{}
+	_ Render = AsciiJSONRender{}
+	_ Render = PureJSONRender{}
+	_ Render = XMLRender{}
+	_ Render = StringRender{}
+	_ Render = DataRender{}
+	_ Render = HTMLRender{}
+)
+
+const (
+	jsonContentType       = "application/json"
+	javascriptContentType = "application/javascript"
+	xmlContentType        = "application/xml"
+	plainContentType      = "text/plain"
+	htmlContentType       = "text/html"
+)
+
+func writeContentType(w http.ResponseWriter, contentType string) {
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", contentType)
+	}
+}
+
+type JSONRender struct {
+	Data interface{}
+}
+
+func (r JSONRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+func (r JSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+type IndentedJSONRender struct {
+	Data interface{}
+}
+
+func (r IndentedJSONRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	b, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (r IndentedJSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// SecureJSONRender 在顶层为数组时加上 Prefix, 防止 JSON 劫持
+type SecureJSONRender struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSONRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	b, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(b, []byte("[")) && bytes.HasSuffix(b, []byte("]")) {
+		if _, err = w.Write([]byte(r.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (r SecureJSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+var jsonpCallbackPattern = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(?:\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// JSONPRender 输出 callback(data);, Callback 为空时退化为 JSON
+// Callback 只允许 JavaScript 标识符或以 '.' 连接的属性访问, 否则返回错误
+type JSONPRender struct {
+	Callback string
+	Data     interface{}
+}
+
+func (r JSONPRender) Render(w http.ResponseWriter) error {
+	if r.Callback == "" {
+		return JSONRender{Data: r.Data}.Render(w)
+	}
+	if !jsonpCallbackPattern.MatchString(r.Callback) {
+		return fmt.Errorf("invalid jsonp callback: %q", r.Callback)
+	}
+	r.WriteContentType(w)
+	b, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(r.Callback) + len(b) + 3)
+	buf.WriteString(r.Callback)
+	buf.WriteByte('(')
+	buf.Write(b)
+	buf.WriteString(");")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (r JSONPRender) WriteContentType(w http.ResponseWriter) {
+	if r.Callback == "" {
+		writeContentType(w, jsonContentType)
+		return
+	}
+	writeContentType(w, javascriptContentType)
+}
+
+// AsciiJSONRender 将非 ASCII 字符转义为 \uXXXX
+type AsciiJSONRender struct {
+	Data interface{}
+}
+
+func (r AsciiJSONRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	b, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(b))
+	for len(b) > 0 {
+		c, size := utf8.DecodeRune(b)
+		b = b[size:]
+		switch {
+		case c < utf8.RuneSelf:
+			buf.WriteByte(byte(c))
+		case c > 0xFFFF:
+			// 超出 BMP 的字符使用 UTF-16 代理对
+			c -= 0x10000
+			fmt.Fprintf(&buf, `\u%04x\u%04x`, 0xD800+(c>>10), 0xDC00+(c&0x3FF))
+		default:
+			fmt.Fprintf(&buf, `\u%04x`, c)
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (r AsciiJSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// PureJSONRender 不转义 <, > 和 &
+type PureJSONRender struct {
+	Data interface{}
+}
+
+func (r PureJSONRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r.Data)
+}
+
+func (r PureJSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+type XMLRender struct {
+	Data interface{}
+}
+
+func (r XMLRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+func (r XMLRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, xmlContentType)
+}
+
+// StringRender 在 Data 为空时原样输出 Format
+type StringRender struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r StringRender) Render(w http.ResponseWriter) (err error) {
+	r.WriteContentType(w)
+	if len(r.Data) == 0 {
+		_, err = w.Write([]byte(r.Format))
+		return
+	}
+	_, err = fmt.Fprintf(w, r.Format, r.Data...)
+	return
+}
+
+func (r StringRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, plainContentType)
+}
+
+// DataRender 在 ContentType 为空时不设置 Content-Type, 由 net/http 根据内容推断
+type DataRender struct {
+	ContentType string
+	Data        []byte
+}
+
+func (r DataRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r DataRender) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, r.ContentType)
+	}
+}
+
+type HTMLRender struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+func (r HTMLRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	if r.Template == nil {
+		return fmt.Errorf("html template %q: templates are not loaded", r.Name)
+	}
+	return r.Template.ExecuteTemplate(w, r.Name, r.Data)
+}
+
+func (r HTMLRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, htmlContentType)
+}
+
+// bodyAllowedForStatus 与 net/http 一致, 1xx、204 和 304 不允许有 body
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
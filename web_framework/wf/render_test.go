@@ -0,0 +1,161 @@
+package wf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenders(t *testing.T) {
+	r := New()
+	var errs Errors
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.GET("/indented", func(c *Context) { c.IndentedJSON(http.StatusOK, H{"a": 1}) })
+	r.GET("/secure", func(c *Context) { c.SecureJSON(http.StatusOK, []int{1, 2}) })
+	r.GET("/secure-object", func(c *Context) { c.SecureJSON(http.StatusOK, H{"a": 1}) })
+	r.GET("/jsonp", func(c *Context) { c.JSONP(http.StatusOK, H{"a": 1}) })
+	r.GET("/ascii", func(c *Context) { c.AsciiJSON(http.StatusOK, H{"lang": "GO语言😀"}) })
+	r.GET("/pure", func(c *Context) { c.PureJSON(http.StatusOK, H{"html": "<b>"}) })
+	r.GET("/json", func(c *Context) { c.JSON(http.StatusOK, H{"html": "<b>"}) })
+	r.GET("/xml", func(c *Context) {
+		c.XML(http.StatusOK, struct {
+			XMLName struct{} `xml:"user"`
+			Name    string   `xml:"name"`
+		}{Name: "geek"})
+	})
+	r.GET("/string", func(c *Context) { c.String(http.StatusOK, "100%") })
+	r.GET("/no-content", func(c *Context) { c.JSON(http.StatusNoContent, H{"a": 1}) })
+	r.GET("/fail", func(c *Context) { c.JSON(http.StatusOK, make(chan int)) })
+
+	w := performRequest(r, "GET", "/indented")
+	require.Equal(t, "{\n    \"a\": 1\n}", w.Body.String())
+	w = performRequest(r, "GET", "/secure")
+	require.Equal(t, "while(1);[1,2]", w.Body.String())
+	w = performRequest(r, "GET", "/secure-object")
+	require.Equal(t, `{"a":1}`, w.Body.String())
+
+	w = performRequest(r, "GET", "/jsonp?callback=app.cb")
+	require.Equal(t, `app.cb({"a":1});`, w.Body.String())
+	require.Equal(t, "application/javascript", w.Header().Get("Content-Type"))
+	w = performRequest(r, "GET", "/jsonp")
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	w = performRequest(r, "GET", "/jsonp?callback=alert(1)//")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = performRequest(r, "GET", "/ascii")
+	require.Equal(t, `{"lang":"GO\u8bed\u8a00\ud83d\ude00"}`, w.Body.String())
+	w = performRequest(r, "GET", "/pure")
+	require.Equal(t, "{\"html\":\"<b>\"}\n", w.Body.String())
+	w = performRequest(r, "GET", "/json")
+	require.Equal(t, "{\"html\":\"\\u003cb\\u003e\"}\n", w.Body.String())
+
+	w = performRequest(r, "GET", "/xml")
+	require.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	require.Equal(t, "<user><name>geek</name></user>", w.Body.String())
+	w = performRequest(r, "GET", "/string")
+	require.Equal(t, "100%", w.Body.String())
+
+	w = performRequest(r, "GET", "/no-content")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Body.String())
+
+	w = performRequest(r, "GET", "/fail")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Len(t, errs, 1)
+	require.True(t, errs[0].IsType(ErrorTypeRender))
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		accept  string
+		offered []string
+		want    string
+	}{
+		{"", []string{"application/json", "application/xml"}, "application/json"},
+		{"application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+		{"text/*;q=0.5, application/json;q=0.4", []string{"application/json", "text/html"}, "text/html"},
+		{"*/*;q=0.1, application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+		{"application/*, application/json;q=0", []string{"application/json", "application/xml"}, "application/xml"},
+		{"text/html;level=1;q=0.9, */*;q=0.9", []string{"application/json", "text/html"}, "application/json"},
+		{"image/png", []string{"application/json"}, ""},
+		{"application/json;q=abc, text/plain", []string{"application/json", "text/plain"}, "text/plain"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		c := &Context{Request: req}
+		require.Equal(t, tc.want, c.NegotiateFormat(tc.offered...), tc.accept)
+	}
+}
+
+type csvRender struct{ rows [][]string }
+
+func (r csvRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	for _, row := range r.rows {
+		if _, err := w.Write([]byte(row[0] + "," + row[1] + "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r csvRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/csv")
+}
+
+func TestNegotiate(t *testing.T) {
+	r := New()
+	var errs Errors
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.RegisterRender("text/csv", func(data interface{}) Render {
+		return csvRender{rows: data.([][]string)}
+	})
+	r.GET("/users", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered:  []string{"application/json", "application/xml", "text/csv"},
+			JSONData: H{"name": "geek"},
+			Data:     [][]string{{"name", "geek"}},
+		})
+	})
+
+	send := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("application/json")
+	require.JSONEq(t, `{"name":"geek"}`, w.Body.String())
+	require.Equal(t, "Accept", w.Header().Get("Vary"))
+	w = send("text/csv, application/json;q=0.9")
+	require.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	require.Equal(t, "name,geek\n", w.Body.String())
+
+	w = send("image/webp")
+	require.Equal(t, http.StatusNotAcceptable, w.Code)
+	require.True(t, errs.Last().IsType(ErrorTypePublic))
+
+	// 没有注册 Render 的格式不会 panic
+	r.GET("/yaml", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{Offered: []string{"application/yaml"}, Data: H{"name": "geek"}})
+	})
+	w = performRequest(r, "GET", "/yaml")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Len(t, errs, 1)
+	require.True(t, errs.Last().IsType(ErrorTypePrivate))
+
+	require.Panics(t, func() { r.RegisterRender("", nil) })
+}
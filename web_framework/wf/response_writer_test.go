@@ -0,0 +1,114 @@
+package wf
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &responseWriter{}
+	w.reset(rec)
+
+	require.Equal(t, http.StatusOK, w.Status())
+	require.Equal(t, noWritten, w.Size())
+	require.False(t, w.Written())
+
+	w.WriteHeader(http.StatusCreated)
+	require.False(t, w.Written())
+	require.False(t, rec.Flushed)
+
+	n, err := w.WriteString("hello")
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	n, err = w.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.Equal(t, 6, n)
+	require.True(t, w.Written())
+	require.Equal(t, 11, w.Size())
+
+	// 已写出后不能再修改状态码
+	w.WriteHeader(http.StatusInternalServerError)
+	require.Equal(t, http.StatusCreated, w.Status())
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	w.Flush()
+	require.True(t, rec.Flushed)
+	require.Nil(t, w.CloseNotify())
+	require.ErrorIs(t, w.Push("/style.css", nil), http.ErrNotSupported)
+	_, _, err = w.Hijack()
+	require.ErrorIs(t, err, http.ErrNotSupported)
+	require.Same(t, rec, w.Unwrap())
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	err      error
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h.err != nil {
+		return nil, nil, h.err
+	}
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := &responseWriter{}
+	w.reset(rec)
+
+	_, _, err := w.Hijack()
+	require.NoError(t, err)
+	require.True(t, rec.hijacked)
+	require.True(t, w.Written())
+
+	// Hijack 失败时响应仍未写出, 可以返回错误状态码
+	rec = &hijackRecorder{ResponseRecorder: httptest.NewRecorder(), err: http.ErrHijacked}
+	w.reset(rec)
+	_, _, err = w.Hijack()
+	require.ErrorIs(t, err, http.ErrHijacked)
+	require.False(t, w.Written())
+	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeaderNow()
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestContextStatusTracking(t *testing.T) {
+	r := New()
+	var status, size int
+	r.Use(func(c *Context) {
+		c.Next()
+		status, size = c.Writer.Status(), c.Writer.Size()
+	})
+	r.GET("/data", func(c *Context) { c.Data(http.StatusAccepted, []byte("abc")) })
+	r.GET("/twice", func(c *Context) {
+		c.String(http.StatusOK, "first")
+		c.JSON(http.StatusInternalServerError, H{"error": "late"})
+	})
+	r.GET("/nobody", func(c *Context) { c.AbortWithStatus(http.StatusNoContent) })
+
+	w := performRequest(r, "GET", "/data")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, http.StatusAccepted, status)
+	require.Equal(t, 3, size)
+
+	w = performRequest(r, "GET", "/twice")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, status)
+
+	w = performRequest(r, "GET", "/nobody")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, http.StatusNoContent, status)
+	require.Equal(t, 0, size)
+
+	performRequest(r, "GET", "/missing")
+	require.Equal(t, http.StatusNotFound, status)
+}
@@ -2,8 +2,15 @@ package wf
 
 import (
 	"net/http"
+	"strings"
 )
 
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete,
+	http.MethodPatch, http.MethodHead, http.MethodOptions,
+	http.MethodConnect, http.MethodTrace,
+}
+
 type RouterGroup struct {
 	prefix   string
 	handlers HandlersChain
@@ -25,36 +32,54 @@ func (group *RouterGroup) Use(middlewares ...HandlerFunc) *RouterGroup {
 	return group
 }
 
-func (group *RouterGroup) GET(relativePath string, handler ...HandlerFunc) {
-	group.addRoute("GET", group.prefix+relativePath, handler)
+func (group *RouterGroup) Handle(method, relativePath string, handler ...HandlerFunc) *Route {
+	if method == "" || strings.ToUpper(method) != method {
+		panic("Invalid http method: " + method)
+	}
+	n := group.addRoute(method, group.prefix+relativePath, handler)
+	return &Route{nodes: []*node{n}, engine: group.engine}
 }
 
-func (group *RouterGroup) POST(relativePath string, handler ...HandlerFunc) {
-	group.addRoute("POST", group.prefix+relativePath, handler)
+func (group *RouterGroup) GET(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodGet, relativePath, handler...)
 }
 
-func (group *RouterGroup) Static(relativePath, root string) {
-	handler := group.createStaticHandler(relativePath, http.Dir(root))
-	path := joinPath(relativePath, "/:filepath")
-	group.GET(path, handler)
+func (group *RouterGroup) POST(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodPost, relativePath, handler...)
 }
 
-func (group *RouterGroup) addRoute(method string, relativePath string, handlers HandlersChain) {
-	handlers = combineHandlers(group.handlers, handlers)
-	group.engine.addRoute(method, relativePath, handlers)
+func (group *RouterGroup) PUT(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodPut, relativePath, handler...)
 }
 
-func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	path := joinPath(group.prefix, relativePath)
-	fileServer := http.StripPrefix(path, http.FileServer(fs))
-	return func(c *Context) {
-		file := c.Param("filepath")
-		if _, err := fs.Open(file); err != nil {
-			c.Status(http.StatusNotFound)
-			return
-		}
-		fileServer.ServeHTTP(c.Writer, c.Request)
+func (group *RouterGroup) DELETE(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodDelete, relativePath, handler...)
+}
+
+func (group *RouterGroup) PATCH(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodPatch, relativePath, handler...)
+}
+
+func (group *RouterGroup) HEAD(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodHead, relativePath, handler...)
+}
+
+func (group *RouterGroup) OPTIONS(relativePath string, handler ...HandlerFunc) *Route {
+	return group.Handle(http.MethodOptions, relativePath, handler...)
+}
+
+// Any 为 anyMethods 中的所有方法注册同一路由
+func (group *RouterGroup) Any(relativePath string, handler ...HandlerFunc) *Route {
+	route := &Route{engine: group.engine}
+	for _, method := range anyMethods {
+		route.nodes = append(route.nodes, group.Handle(method, relativePath, handler...).nodes...)
 	}
+	return route
+}
+
+func (group *RouterGroup) addRoute(method string, relativePath string, handlers HandlersChain) *node {
+	handlers = combineHandlers(group.handlers, handlers)
+	return group.engine.addRoute(method, relativePath, handlers)
 }
 
 func combineHandlers(former, latter HandlersChain) HandlersChain {
@@ -0,0 +1,135 @@
+package wf
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route 为注册路由的返回值, 用于给路由命名
+type Route struct {
+	nodes  []*node
+	engine *Engine
+}
+
+// Name 为路由命名, 用于 Engine.URL 生成路径, 同一名称不能指向不同路径
+func (r *Route) Name(name string) *Route {
+	if name == "" {
+		panic("Empty route name")
+	}
+	path := r.nodes[0].path
+	if old, ok := r.engine.namedRoutes[name]; ok && old != path {
+		panic("Duplicate route name: " + name)
+	}
+	if r.engine.namedRoutes == nil {
+		r.engine.namedRoutes = make(map[string]string)
+	}
+	r.engine.namedRoutes[name] = path
+	for _, n := range r.nodes {
+		n.name = name
+	}
+	return r
+}
+
+type RouteInfo struct {
+	Method      string
+	Path        string
+	Name        string
+	Handler     string
+	Middlewares int
+	HandlerFunc HandlerFunc
+}
+
+type RoutesInfo []RouteInfo
+
+// Routes 返回所有已注册的路由, 按路径和方法排序
+func (engine *Engine) Routes() RoutesInfo {
+	var routes RoutesInfo
+	for method, root := range engine.roots {
+		root.walk(func(n *node) {
+			info := RouteInfo{Method: method, Path: n.path, Name: n.name}
+			if len(n.handlers) != 0 {
+				info.HandlerFunc = n.handlers[len(n.handlers)-1]
+				info.Handler = nameOfFunction(info.HandlerFunc)
+				info.Middlewares = len(n.handlers) - 1
+			}
+			routes = append(routes, info)
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func nameOfFunction(f interface{}) string {
+	if f == nil || reflect.ValueOf(f).IsNil() {
+		return ""
+	}
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// URL 按路由名称生成路径, pairs 为参数名和值交替出现的列表
+// eg: URL("user.show", "id", "42") -> /users/42
+// 参数值会被转义, catch-all 参数保留 '/', 路由中不存在的参数作为 query 追加
+func (engine *Engine) URL(name string, pairs ...string) (string, error) {
+	path, ok := engine.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("route %q not found", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", errors.New("URL: odd number of key/value pairs")
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+
+	var str strings.Builder
+	for _, part := range parsePath(path) {
+		str.WriteByte('/')
+		switch part[0] {
+		case ':', '*':
+			key, constraint := splitConstraint(part[1:])
+			value, ok := values[key]
+			if !ok || value == "" {
+				return "", fmt.Errorf("route %q: missing parameter %q", name, key)
+			}
+			if constraint != "" && !compileConstraint(constraint)(value) {
+				return "", fmt.Errorf("route %q: parameter %q does not match <%s>", name, key, constraint)
+			}
+			delete(values, key)
+			if part[0] == ':' {
+				str.WriteString(url.PathEscape(value))
+				continue
+			}
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for i, seg := range segments {
+				segments[i] = url.PathEscape(seg)
+			}
+			str.WriteString(strings.Join(segments, "/"))
+		default:
+			str.WriteString(part)
+		}
+	}
+	if str.Len() == 0 {
+		str.WriteByte('/')
+	}
+
+	if len(values) != 0 {
+		query := make(url.Values, len(values))
+		for k, v := range values {
+			query.Set(k, v)
+		}
+		str.WriteByte('?')
+		str.WriteString(query.Encode())
+	}
+	return str.String(), nil
+}
@@ -0,0 +1,92 @@
+package wf
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func showUser(c *Context) {}
+
+func TestRoutes(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	r.GET("/users/:id", showUser).Name("user.show")
+	api := r.Group("/api")
+	api.Use(func(c *Context) {})
+	api.POST("/items", func(c *Context) {})
+	r.Any("/any", showUser)
+
+	routes := r.Routes()
+	require.Len(t, routes, 2+len(anyMethods))
+	require.Equal(t, "/any", routes[0].Path)
+
+	var show, items RouteInfo
+	for _, route := range routes {
+		switch route.Path {
+		case "/users/:id":
+			show = route
+		case "/api/items":
+			items = route
+		}
+	}
+	require.Equal(t, "GET", show.Method)
+	require.Equal(t, "user.show", show.Name)
+	require.Equal(t, "wf.showUser", show.Handler)
+	require.Equal(t, 1, show.Middlewares)
+	require.NotNil(t, show.HandlerFunc)
+
+	require.Equal(t, "POST", items.Method)
+	require.Empty(t, items.Name)
+	require.Equal(t, 2, items.Middlewares)
+}
+
+func TestURL(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", nil).Name("user.show")
+	r.GET("/users/:id/posts/:post", nil).Name("post.show")
+	r.GET("/files/*filepath", nil).Name("files")
+	r.Any("/", nil).Name("home")
+	r.POST("/users/:id", nil).Name("user.show")
+	require.Panics(t, func() { r.GET("/other", nil).Name("user.show") })
+
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{"user.show", []string{"id", "42"}, "/users/42"},
+		{"user.show", []string{"id", "a b/c"}, "/users/a%20b%2Fc"},
+		{"post.show", []string{"id", "1", "post", "2", "page", "3"}, "/users/1/posts/2?page=3"},
+		{"files", []string{"filepath", "css/my site.css"}, "/files/css/my%20site.css"},
+		{"home", nil, "/"},
+	}
+	for _, test := range tests {
+		got, err := r.URL(test.name, test.pairs...)
+		require.NoError(t, err)
+		require.Equal(t, test.want, got)
+	}
+
+	_, err := r.URL("missing")
+	require.Error(t, err)
+	_, err = r.URL("user.show")
+	require.Error(t, err)
+	_, err = r.URL("user.show", "id")
+	require.Error(t, err)
+}
+
+func TestURLTemplateFunc(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.tmpl"), []byte(`<a href="{{url "user.show" "id" .}}">`), 0o644))
+
+	r := New()
+	r.GET("/users/:id", nil).Name("user.show")
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	r.GET("/", func(c *Context) { c.HTML(http.StatusOK, "index.tmpl", "42") })
+
+	w := performRequest(r, "GET", "/")
+	require.Equal(t, `<a href="/users/42">`, w.Body.String())
+}
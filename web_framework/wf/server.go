@@ -0,0 +1,161 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Server 返回 Run 系列方法使用的 http.Server, 可以在启动前修改超时等配置
+func (engine *Engine) Server() *http.Server {
+	engine.serverMu.Lock()
+	defer engine.serverMu.Unlock()
+	if engine.server == nil {
+		engine.server = &http.Server{Handler: engine}
+	}
+	return engine.server
+}
+
+func (engine *Engine) Run(address string) error {
+	srv := engine.Server()
+	srv.Addr = address
+	return srv.ListenAndServe()
+}
+
+func (engine *Engine) RunTLS(address, certFile, keyFile string) error {
+	srv := engine.Server()
+	srv.Addr = address
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
+
+// RunUnix 监听 unix socket, 退出时删除 socket 文件
+func (engine *Engine) RunUnix(file string) error {
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return engine.RunListener(listener)
+}
+
+func (engine *Engine) RunListener(listener net.Listener) error {
+	return engine.Server().Serve(listener)
+}
+
+// RunContext 在 ctx 取消或收到 SIGTERM/SIGINT 时调用 Shutdown, 最多等待 ShutdownTimeout
+// 正常关闭时返回 nil
+func (engine *Engine) RunContext(ctx context.Context, address string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := engine.Server()
+	srv.Addr = address
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), engine.ShutdownTimeout)
+	defer cancel()
+	if err := engine.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// OnShutdown 注册 Shutdown 在请求处理完成后执行的函数
+func (engine *Engine) OnShutdown(f func()) {
+	engine.serverMu.Lock()
+	engine.onShutdown = append(engine.onShutdown, f)
+	engine.serverMu.Unlock()
+}
+
+// Shutdown 停止接受新连接, 等待处理中的请求和被 Hijack 的连接结束后执行 OnShutdown 注册的函数
+// ctx 结束时强制关闭剩余的 Hijack 连接并返回 ctx.Err()
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	err := engine.Server().Shutdown(ctx)
+	if hijackErr := engine.waitHijacked(ctx); err == nil {
+		err = hijackErr
+	}
+
+	engine.serverMu.Lock()
+	hooks := engine.onShutdown
+	engine.serverMu.Unlock()
+	for _, f := range hooks {
+		f()
+	}
+	return err
+}
+
+const hijackedPollInterval = 10 * time.Millisecond
+
+func (engine *Engine) waitHijacked(ctx context.Context) error {
+	ticker := time.NewTicker(hijackedPollInterval)
+	defer ticker.Stop()
+	for {
+		engine.hijackedMu.Lock()
+		n := len(engine.hijackedConns)
+		engine.hijackedMu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			engine.hijackedMu.Lock()
+			conns := make([]*hijackedConn, 0, len(engine.hijackedConns))
+			for conn := range engine.hijackedConns {
+				conns = append(conns, conn)
+			}
+			engine.hijackedMu.Unlock()
+			for _, conn := range conns {
+				conn.Close()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (engine *Engine) trackHijacked(conn net.Conn) net.Conn {
+	hc := &hijackedConn{Conn: conn, engine: engine}
+	engine.hijackedMu.Lock()
+	if engine.hijackedConns == nil {
+		engine.hijackedConns = make(map[*hijackedConn]struct{})
+	}
+	engine.hijackedConns[hc] = struct{}{}
+	engine.hijackedMu.Unlock()
+	return hc
+}
+
+// hijackedConn 在关闭时从 Engine 中移除, 使 Shutdown 可以等待它
+type hijackedConn struct {
+	net.Conn
+	once   sync.Once
+	engine *Engine
+}
+
+func (c *hijackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.engine.hijackedMu.Lock()
+		delete(c.engine.hijackedConns, c)
+		c.engine.hijackedMu.Unlock()
+	})
+	return err
+}
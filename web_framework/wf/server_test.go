@@ -0,0 +1,166 @@
+package wf
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func startListener(t *testing.T, r *Engine) (string, chan error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.RunListener(l) }()
+	return l.Addr().String(), errCh
+}
+
+func TestShutdownWaitsForRequests(t *testing.T) {
+	r := New()
+	started := make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	var hooks int32
+	r.OnShutdown(func() { atomic.AddInt32(&hooks, 1) })
+
+	addr, errCh := startListener(t, r)
+	respCh := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			respCh <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respCh <- string(body)
+	}()
+
+	<-started
+	require.NoError(t, r.Shutdown(context.Background()))
+	require.Equal(t, "done", <-respCh)
+	require.Equal(t, int32(1), atomic.LoadInt32(&hooks))
+	require.ErrorIs(t, <-errCh, http.ErrServerClosed)
+
+	_, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+	require.Error(t, err)
+}
+
+func newHijackEngine(t *testing.T, hijacked chan net.Conn) *Engine {
+	r := New()
+	r.GET("/hijack", func(c *Context) {
+		conn, rw, err := c.Writer.Hijack()
+		require.NoError(t, err)
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		_ = rw.Flush()
+		hijacked <- conn
+	})
+	return r
+}
+
+func dialHijack(t *testing.T, addr string) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("GET /hijack HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	require.Contains(t, line, "101")
+	return conn
+}
+
+func TestShutdownHijacked(t *testing.T) {
+	hijacked := make(chan net.Conn, 1)
+	r := newHijackEngine(t, hijacked)
+	addr, _ := startListener(t, r)
+	client := dialHijack(t, addr)
+	defer client.Close()
+	server := <-hijacked
+
+	// 连接在超时前关闭, Shutdown 正常返回
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.Shutdown(ctx))
+}
+
+func TestShutdownHijackedTimeout(t *testing.T) {
+	hijacked := make(chan net.Conn, 1)
+	r := newHijackEngine(t, hijacked)
+	addr, _ := startListener(t, r)
+	client := dialHijack(t, addr)
+	defer client.Close()
+	<-hijacked
+
+	// 超时后强制关闭
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, r.Shutdown(ctx), context.DeadlineExceeded)
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := client.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRunContext(t *testing.T) {
+	r := New()
+	r.GET("/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.RunContext(ctx, addr) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/ping")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errCh)
+}
+
+func TestRunUnix(t *testing.T) {
+	r := New()
+	r.GET("/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+	file := filepath.Join(t.TempDir(), "wf.sock")
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.RunUnix(file) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", file)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://unix/ping")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, r.Shutdown(context.Background()))
+	require.ErrorIs(t, <-errCh, http.ErrServerClosed)
+}
@@ -0,0 +1,168 @@
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"wf"
+)
+
+// maxCookieSize 为浏览器通常允许的单个 cookie 的最大长度
+const maxCookieSize = 4096
+
+var errCookieTooLong = errors.New("sessions: encoded cookie value is too long")
+
+// CookieStore 将会话数据加密后保存在 cookie 中, 服务端不保存状态
+// 数据先用 AES-GCM 加密, 再连同 cookie 名和时间戳用 HMAC-SHA256 签名
+type CookieStore struct {
+	Options Options
+
+	codecs []cookieCodec
+	now    func() time.Time
+}
+
+type cookieCodec struct {
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+// NewCookieStore 的参数为成对的签名密钥和加密密钥, 加密密钥为 16、24 或 32 字节, 为 nil 时只签名不加密
+// 写入时使用第一对密钥, 读取时依次尝试所有密钥, 轮换时将新密钥放在最前面, 旧密钥保留到已签发的 cookie 过期
+func NewCookieStore(keyPairs ...[]byte) *CookieStore {
+	if len(keyPairs) == 0 {
+		panic("sessions: at least one hash key is required")
+	}
+	st := &CookieStore{Options: DefaultOptions(), now: time.Now}
+	for i := 0; i < len(keyPairs); i += 2 {
+		codec := cookieCodec{hashKey: keyPairs[i]}
+		if len(codec.hashKey) == 0 {
+			panic("sessions: hash key is empty")
+		}
+		if i+1 < len(keyPairs) && keyPairs[i+1] != nil {
+			block, err := aes.NewCipher(keyPairs[i+1])
+			if err != nil {
+				panic("sessions: invalid block key: " + err.Error())
+			}
+			if codec.aead, err = cipher.NewGCM(block); err != nil {
+				panic("sessions: " + err.Error())
+			}
+		}
+		st.codecs = append(st.codecs, codec)
+	}
+	return st
+}
+
+func (st *CookieStore) Load(c *wf.Context, name string) (*Session, error) {
+	s := NewSession(name, st.Options)
+	value, err := c.Cookie(name)
+	if err != nil || value == "" {
+		return s, nil
+	}
+	data, ok := st.decode(name, value)
+	if !ok {
+		return s, nil
+	}
+	values, err := decodeValues(data)
+	if err != nil {
+		return s, nil
+	}
+	s.Values, s.IsNew = values, false
+	return s, nil
+}
+
+func (st *CookieStore) Save(c *wf.Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		c.SetCookie(s.Name, "", s.Options.cookie())
+		return nil
+	}
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	value, err := st.encode(s.Name, data)
+	if err != nil {
+		return err
+	}
+	c.SetCookie(s.Name, value, s.Options.cookie())
+	return nil
+}
+
+// encode 返回 base64(时间戳).base64(密文).base64(HMAC)
+// HMAC 覆盖 cookie 名, 防止同一密钥签发的不同 cookie 互相替换
+func (st *CookieStore) encode(name string, data []byte) (string, error) {
+	codec := st.codecs[0]
+	if codec.aead != nil {
+		nonce := make([]byte, codec.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		data = codec.aead.Seal(nonce, nonce, data, []byte(name))
+	}
+
+	payload := strconv.FormatInt(st.now().Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(data)
+	value := payload + "." + base64.RawURLEncoding.EncodeToString(codec.mac(name, payload))
+	if len(name)+len(value) > maxCookieSize {
+		return "", errCookieTooLong
+	}
+	return value, nil
+}
+
+func (st *CookieStore) decode(name, value string) ([]byte, bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return nil, false
+	}
+	payload := value[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return nil, false
+	}
+	ts, encoded, ok := strings.Cut(payload, ".")
+	if !ok {
+		return nil, false
+	}
+
+	for _, codec := range st.codecs {
+		if !hmac.Equal(mac, codec.mac(name, payload)) {
+			continue
+		}
+		// cookie 的 MaxAge 由浏览器执行, 服务端同样校验签发时间, 防止重放过期的 cookie
+		issued, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		if maxAge := st.Options.MaxAge; maxAge > 0 && st.now().Unix()-issued > int64(maxAge) {
+			return nil, false
+		}
+		data, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, false
+		}
+		if codec.aead == nil {
+			return data, true
+		}
+		nonceSize := codec.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, false
+		}
+		data, err = codec.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(name))
+		return data, err == nil
+	}
+	return nil, false
+}
+
+func (codec cookieCodec) mac(name, payload string) []byte {
+	h := hmac.New(sha256.New, codec.hashKey)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,23 @@
+package sessions
+
+import "wf"
+
+const csrfTokenKey = "_csrf_token"
+
+type csrfStore struct{}
+
+// CSRFStore 返回将 CSRF 令牌保存在 Default 会话中的 wf.CSRFStore, 需要在 Sessions 中间件之后使用
+func CSRFStore() wf.CSRFStore {
+	return csrfStore{}
+}
+
+func (csrfStore) Token(c *wf.Context) (string, error) {
+	token, _ := Default(c).Get(csrfTokenKey).(string)
+	return token, nil
+}
+
+func (csrfStore) SaveToken(c *wf.Context, token string) error {
+	s := Default(c)
+	s.Set(csrfTokenKey, token)
+	return s.Save()
+}
@@ -0,0 +1,143 @@
+package sessions
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"dc"
+	"wf"
+)
+
+// GroupBackend 为 GroupStore 写入会话数据的共享存储
+// dc.Group 的 Getter 应从同一个存储中按键读取 Set 写入的数据
+type GroupBackend interface {
+	Set(key string, value []byte) error
+	Delete(key string) error
+	// Lookup 直接读取存储, 不经过 dc.Group 的缓存
+	Lookup(key string) (value []byte, ok bool, err error)
+}
+
+// GroupStore 通过 dc.Group 读取会话, 多个实例可以共享会话并利用各节点的缓存
+// 会话 ID 在 Save 之间保持不变, 只有 RenewID 后才会更换
+// 每次 Save 将数据写入 "ID.版本" 这个新键, 再把 ID 键中记录的当前版本指向它,
+// 数据键写入后不再修改, 可以放心地缓存在 dc.Group 中; Load 直接读取很小的版本记录,
+// 所以并发的请求总是读到最新的数据, 注销或轮换后的 ID 也立即失效
+type GroupStore struct {
+	Options Options
+
+	group   *dc.Group
+	backend GroupBackend
+	now     func() time.Time
+}
+
+func NewGroupStore(group *dc.Group, backend GroupBackend) *GroupStore {
+	if group == nil || backend == nil {
+		panic("sessions: group and backend are required")
+	}
+	return &GroupStore{Options: DefaultOptions(), group: group, backend: backend, now: time.Now}
+}
+
+// Load 在 ID 没有版本记录或 Group 中找不到数据时返回新会话, 读取版本记录出错时返回新会话和错误
+func (st *GroupStore) Load(c *wf.Context, name string) (*Session, error) {
+	s := NewSession(name, st.Options)
+	id, err := c.Cookie(name)
+	if err != nil || id == "" {
+		return s, nil
+	}
+	version, ok, err := st.backend.Lookup(id)
+	if err != nil || !ok {
+		return s, err
+	}
+	view, err := st.group.Get(dataKey(id, string(version)))
+	if err != nil {
+		return s, nil
+	}
+
+	data := view.ByteSlice()
+	if len(data) < 8 {
+		return s, errors.New("sessions: invalid group entry")
+	}
+	if expires := int64(binary.BigEndian.Uint64(data)); expires != 0 && st.now().Unix() >= expires {
+		return s, nil
+	}
+	values, err := decodeValues(data[8:])
+	if err != nil {
+		return s, err
+	}
+	s.ID, s.Values, s.IsNew = id, values, false
+	return s, nil
+}
+
+func (st *GroupStore) Save(c *wf.Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		c.SetCookie(s.Name, "", s.Options.cookie())
+		if err := st.remove(s.oldID); err != nil {
+			return err
+		}
+		id := s.ID
+		s.ID, s.oldID = "", ""
+		return st.remove(id)
+	}
+
+	encoded, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	// 前 8 字节为过期时间的 Unix 秒数, 0 表示不过期
+	data := make([]byte, 8+len(encoded))
+	if s.Options.MaxAge > 0 {
+		binary.BigEndian.PutUint64(data, uint64(st.now().Unix()+int64(s.Options.MaxAge)))
+	}
+	copy(data[8:], encoded)
+
+	if s.ID == "" {
+		if s.ID, err = newID(); err != nil {
+			return err
+		}
+	}
+	version, err := newID()
+	if err != nil {
+		return err
+	}
+	// 记录中的版本可能已被并发的请求更新, 切换前重新读取, 避免遗留数据
+	prev, hasPrev, err := st.backend.Lookup(s.ID)
+	if err != nil {
+		return err
+	}
+	if err = st.backend.Set(dataKey(s.ID, version), data); err != nil {
+		return err
+	}
+	if err = st.backend.Set(s.ID, []byte(version)); err != nil {
+		return err
+	}
+	c.SetCookie(s.Name, s.ID, s.Options.cookie())
+
+	if hasPrev {
+		if err = st.backend.Delete(dataKey(s.ID, string(prev))); err != nil {
+			return err
+		}
+	}
+	oldID := s.oldID
+	s.oldID = ""
+	return st.remove(oldID)
+}
+
+// remove 删除 id 的版本记录和当前版本的数据
+func (st *GroupStore) remove(id string) error {
+	if id == "" {
+		return nil
+	}
+	version, ok, err := st.backend.Lookup(id)
+	if err != nil || !ok {
+		return err
+	}
+	if err = st.backend.Delete(id); err != nil {
+		return err
+	}
+	return st.backend.Delete(dataKey(id, string(version)))
+}
+
+func dataKey(id, version string) string {
+	return id + "." + version
+}
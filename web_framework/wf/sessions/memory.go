@@ -0,0 +1,113 @@
+package sessions
+
+import (
+	"sync"
+	"time"
+
+	"wf"
+)
+
+// MemoryStore 将会话保存在进程内存中, cookie 中只有随机的会话 ID
+// 适用于单实例部署, 过期的会话在读取时或每分钟一次的清理中删除
+type MemoryStore struct {
+	Options Options
+
+	mu        sync.Mutex
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Options:  DefaultOptions(),
+		sessions: make(map[string]memoryEntry),
+		now:      time.Now,
+	}
+}
+
+func (st *MemoryStore) Load(c *wf.Context, name string) (*Session, error) {
+	s := NewSession(name, st.Options)
+	id, err := c.Cookie(name)
+	if err != nil || id == "" {
+		return s, nil
+	}
+
+	st.mu.Lock()
+	entry, ok := st.sessions[id]
+	if ok && st.expired(entry) {
+		delete(st.sessions, id)
+		ok = false
+	}
+	st.mu.Unlock()
+	if !ok {
+		return s, nil
+	}
+
+	// 保存的是编码后的数据, 各请求拿到的是独立的副本
+	values, err := decodeValues(entry.data)
+	if err != nil {
+		return s, err
+	}
+	s.ID, s.Values, s.IsNew = id, values, false
+	return s, nil
+}
+
+func (st *MemoryStore) Save(c *wf.Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		st.mu.Lock()
+		delete(st.sessions, s.ID)
+		delete(st.sessions, s.oldID)
+		st.mu.Unlock()
+		s.ID, s.oldID = "", ""
+		c.SetCookie(s.Name, "", s.Options.cookie())
+		return nil
+	}
+
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	if s.ID == "" {
+		if s.ID, err = newID(); err != nil {
+			return err
+		}
+	}
+	entry := memoryEntry{data: data}
+	if s.Options.MaxAge > 0 {
+		entry.expires = st.now().Add(time.Duration(s.Options.MaxAge) * time.Second)
+	}
+
+	st.mu.Lock()
+	st.sessions[s.ID] = entry
+	delete(st.sessions, s.oldID)
+	st.sweep()
+	st.mu.Unlock()
+	s.oldID = ""
+
+	c.SetCookie(s.Name, s.ID, s.Options.cookie())
+	return nil
+}
+
+func (st *MemoryStore) expired(entry memoryEntry) bool {
+	return !entry.expires.IsZero() && !st.now().Before(entry.expires)
+}
+
+// sweep 至多每分钟删除一次过期会话, 调用者需持有 mu
+func (st *MemoryStore) sweep() {
+	now := st.now()
+	if now.Sub(st.lastSweep) < time.Minute {
+		return
+	}
+	st.lastSweep = now
+	for id, entry := range st.sessions {
+		if st.expired(entry) {
+			delete(st.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package sessions 为 wf 提供基于 cookie 的会话中间件, 存储方式由 Store 决定
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"net/http"
+
+	"wf"
+)
+
+// DefaultKey 为会话在 Context.Keys 中的键
+const DefaultKey = "wf/sessions"
+
+const flashKey = "_flash"
+
+func init() {
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// Options 为会话 cookie 的属性, MaxAge 同时决定服务端数据的过期时间
+type Options struct {
+	Path   string
+	Domain string
+	// MaxAge 为秒数, 为 0 时为浏览器会话 cookie, 小于 0 时删除会话
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultOptions 为各个 Store 的默认选项: 30 天, HttpOnly, SameSite=Lax
+func DefaultOptions() Options {
+	return Options{Path: "/", MaxAge: 86400 * 30, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+}
+
+func (o Options) cookie() wf.CookieOptions {
+	return wf.CookieOptions{
+		Path:     o.Path,
+		Domain:   o.Domain,
+		MaxAge:   o.MaxAge,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+}
+
+// Store 负责读取和持久化会话
+// Values 中自定义类型的值需要先通过 gob.Register 注册
+type Store interface {
+	// Load 读取名为 name 的会话, 没有会话或无法校验时返回 IsNew 为 true 的新会话
+	// 返回错误时也应返回可用的新会话
+	Load(c *wf.Context, name string) (*Session, error)
+	// Save 持久化会话并写出 cookie, Options.MaxAge 小于 0 时删除会话
+	Save(c *wf.Context, s *Session) error
+}
+
+// Session 为一次请求中的会话, 修改后需要调用 Save 才会生效
+type Session struct {
+	Name    string
+	ID      string
+	Values  map[string]interface{}
+	Options Options
+	IsNew   bool
+
+	c     *wf.Context
+	store Store
+	// oldID 为 RenewID 之前的 ID, Save 时由 Store 删除
+	oldID string
+}
+
+// NewSession 返回名为 name 的新会话, 供 Store 的实现使用
+func NewSession(name string, opts Options) *Session {
+	return &Session{Name: name, Values: make(map[string]interface{}), Options: opts, IsNew: true}
+}
+
+// Sessions 返回在 Context 中加载名为 name 的会话的中间件, 之后通过 Default 获取
+// 加载失败时将错误记录到 c.Errors
+func Sessions(name string, store Store) wf.HandlerFunc {
+	return func(c *wf.Context) {
+		s, err := store.Load(c, name)
+		if err != nil {
+			c.Error(err)
+		}
+		if s == nil {
+			s = NewSession(name, DefaultOptions())
+		}
+		s.c, s.store = c, store
+		c.Set(DefaultKey, s)
+		c.Next()
+	}
+}
+
+// Default 返回 Sessions 中间件加载的会话
+func Default(c *wf.Context) *Session {
+	return c.MustGet(DefaultKey).(*Session)
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+// Clear 删除会话中的所有值
+func (s *Session) Clear() {
+	for key := range s.Values {
+		delete(s.Values, key)
+	}
+}
+
+// AddFlash 添加一条闪存消息, vars 可以指定消息的分类, 默认为 "_flash"
+func (s *Session) AddFlash(value interface{}, vars ...string) {
+	key := flashKey
+	if len(vars) > 0 {
+		key = vars[0]
+	}
+	flashes, _ := s.Values[key].([]interface{})
+	s.Values[key] = append(flashes, value)
+}
+
+// Flashes 返回并删除闪存消息, 需要 Save 后删除才会持久化
+func (s *Session) Flashes(vars ...string) []interface{} {
+	key := flashKey
+	if len(vars) > 0 {
+		key = vars[0]
+	}
+	flashes, _ := s.Values[key].([]interface{})
+	delete(s.Values, key)
+	return flashes
+}
+
+// Save 通过 Store 持久化会话并写出 cookie
+func (s *Session) Save() error {
+	return s.store.Save(s.c, s)
+}
+
+// RenewID 使下次 Save 时为会话分配新的 ID 并删除旧 ID, 应在登录或权限变化时调用, 防止会话固定
+// CookieStore 的会话没有 ID, 调用没有效果
+func (s *Session) RenewID() {
+	if s.ID == "" {
+		return
+	}
+	if s.oldID == "" {
+		s.oldID = s.ID
+	}
+	s.ID = ""
+}
+
+// Destroy 删除会话数据和 cookie
+func (s *Session) Destroy() error {
+	s.Clear()
+	s.Options.MaxAge = -1
+	return s.Save()
+}
+
+// newID 返回 256 位随机数的 base64 编码
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func encodeValues(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValues(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
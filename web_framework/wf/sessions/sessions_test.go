@@ -0,0 +1,301 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dc"
+	"wf"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEngine(store Store) *wf.Engine {
+	r := wf.New()
+	r.Use(Sessions("sid", store))
+	save := func(c *wf.Context, s *Session) bool {
+		if err := s.Save(); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return false
+		}
+		return true
+	}
+	r.GET("/set", func(c *wf.Context) {
+		s := Default(c)
+		s.Set("user", c.Query("user"))
+		s.AddFlash("welcome")
+		if save(c, s) {
+			c.String(http.StatusOK, "ok")
+		}
+	})
+	r.GET("/get", func(c *wf.Context) {
+		s := Default(c)
+		flashes := s.Flashes()
+		if save(c, s) {
+			c.String(http.StatusOK, "%v %v %v", s.Get("user"), flashes, s.IsNew)
+		}
+	})
+	r.GET("/renew", func(c *wf.Context) {
+		s := Default(c)
+		s.RenewID()
+		if save(c, s) {
+			c.String(http.StatusOK, "%v", s.Get("user"))
+		}
+	})
+	r.GET("/logout", func(c *wf.Context) {
+		if err := Default(c).Destroy(); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.String(http.StatusOK, "bye")
+	})
+	return r
+}
+
+type client struct {
+	t       *testing.T
+	handler http.Handler
+	cookie  *http.Cookie
+}
+
+func (cl *client) get(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if cl.cookie != nil {
+		req.AddCookie(cl.cookie)
+	}
+	w := httptest.NewRecorder()
+	cl.handler.ServeHTTP(w, req)
+	require.Equal(cl.t, http.StatusOK, w.Code)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "sid" {
+			cl.cookie = cookie
+			if cookie.MaxAge < 0 {
+				cl.cookie = nil
+			}
+		}
+	}
+	return w
+}
+
+func testStore(tt *testing.T, store Store) *client {
+	cl := &client{t: tt, handler: newTestEngine(store)}
+	w := cl.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+
+	cl.get("/set?user=geek")
+	require.NotNil(tt, cl.cookie)
+	require.True(tt, cl.cookie.HttpOnly)
+	require.Equal(tt, http.SameSiteLaxMode, cl.cookie.SameSite)
+
+	w = cl.get("/get")
+	require.Equal(tt, "geek [welcome] false", w.Body.String())
+	w = cl.get("/get")
+	require.Equal(tt, "geek [] false", w.Body.String())
+
+	saved := cl.cookie
+	cl.get("/logout")
+	require.Nil(tt, cl.cookie)
+	w = cl.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+	cl.cookie = saved
+	return cl
+}
+
+func TestMemoryStore(tt *testing.T) {
+	store := NewMemoryStore()
+	cl := testStore(tt, store)
+	// 注销后旧的会话 ID 失效
+	w := cl.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	cl.cookie = nil
+	cl.get("/set?user=a")
+	expired := cl.cookie.Value
+	now = now.Add(31 * 24 * time.Hour)
+	w = cl.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+	require.NotContains(tt, store.sessions, expired)
+
+	cl.get("/set?user=b")
+	first := cl.cookie.Value
+	w = cl.get("/renew")
+	require.Equal(tt, "b", w.Body.String())
+	require.NotEqual(tt, first, cl.cookie.Value)
+	require.NotContains(tt, store.sessions, first)
+}
+
+func TestCookieStore(tt *testing.T) {
+	oldKeys := [][]byte{[]byte("old-hash-key"), []byte("0123456789abcdef")}
+	store := NewCookieStore(oldKeys...)
+	cl := testStore(tt, store)
+	// cookie 中保存的是数据本身, 注销前的 cookie 仍然有效
+	w := cl.get("/get")
+	require.Equal(tt, "geek [] false", w.Body.String())
+	require.NotContains(tt, cl.cookie.Value, "geek")
+
+	// 轮换密钥后旧 cookie 仍可读取, 新 cookie 使用新密钥
+	rotated := NewCookieStore([]byte("new-hash-key"), []byte("fedcba9876543210fedcba9876543210"), oldKeys[0], oldKeys[1])
+	cl.handler = newTestEngine(rotated)
+	w = cl.get("/get")
+	require.Equal(tt, "geek [] false", w.Body.String())
+	_, ok := store.decode("sid", cl.cookie.Value)
+	require.False(tt, ok)
+	_, ok = rotated.decode("sid", cl.cookie.Value)
+	require.True(tt, ok)
+
+	// 篡改、换名或过期的 cookie 视为新会话
+	value := cl.cookie.Value
+	tampered := value[:len(value)-2] + "AA"
+	_, ok = rotated.decode("sid", tampered)
+	require.False(tt, ok)
+	_, ok = rotated.decode("other", value)
+	require.False(tt, ok)
+	rotated.now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	_, ok = rotated.decode("sid", value)
+	require.False(tt, ok)
+
+	signed := NewCookieStore([]byte("hash-only"))
+	encoded, err := signed.encode("sid", []byte("data"))
+	require.NoError(tt, err)
+	data, ok := signed.decode("sid", encoded)
+	require.True(tt, ok)
+	require.Equal(tt, "data", string(data))
+	_, err = signed.encode("sid", []byte(strings.Repeat("x", maxCookieSize)))
+	require.Equal(tt, errCookieTooLong, err)
+
+	require.Panics(tt, func() { NewCookieStore() })
+	require.Panics(tt, func() { NewCookieStore([]byte("k"), []byte("short")) })
+}
+
+type mapBackend struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (b *mapBackend) Set(key string, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data[key] = value
+	return nil
+}
+
+func (b *mapBackend) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.data, key)
+	return nil
+}
+
+func (b *mapBackend) Lookup(key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.data[key]
+	return v, ok, nil
+}
+
+func (b *mapBackend) Get(key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if v, ok := b.data[key]; ok {
+		return v, nil
+	}
+	return nil, errors.New("session not found")
+}
+
+func TestGroupStore(tt *testing.T) {
+	backend := &mapBackend{data: make(map[string][]byte)}
+	group := dc.NewGroup(fmt.Sprintf("sessions-%d", time.Now().UnixNano()), 1<<20, dc.GetterFunc(backend.Get))
+	store := NewGroupStore(group, backend)
+	cl := testStore(tt, store)
+	require.NotContains(tt, backend.data, cl.cookie.Value)
+	// 注销前的会话已缓存在 Group 中, 重放旧 cookie 仍得到新会话
+	w := cl.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+
+	// ID 在 Save 之间不变, 只保留当前版本的数据
+	cl = &client{t: tt, handler: newTestEngine(store)}
+	backend.data = make(map[string][]byte)
+	cl.get("/set?user=a")
+	first := cl.cookie
+	w = cl.get("/get")
+	require.Equal(tt, "a [welcome] false", w.Body.String())
+	require.Equal(tt, first.Value, cl.cookie.Value)
+	require.Len(tt, backend.data, 2)
+
+	// RenewID 后旧 ID 失效
+	w = cl.get("/renew")
+	require.Equal(tt, "a", w.Body.String())
+	require.NotEqual(tt, first.Value, cl.cookie.Value)
+	require.Len(tt, backend.data, 2)
+	replay := &client{t: tt, handler: cl.handler, cookie: first}
+	w = replay.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+
+	store.now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	w = cl.get("/get")
+	require.Equal(tt, "<nil> [] true", w.Body.String())
+}
+
+func TestGroupStoreConcurrentRequests(tt *testing.T) {
+	backend := &mapBackend{data: make(map[string][]byte)}
+	group := dc.NewGroup(fmt.Sprintf("sessions-%d", time.Now().UnixNano()), 1<<20, dc.GetterFunc(backend.Get))
+	store := NewGroupStore(group, backend)
+	r := newTestEngine(store)
+	cl := &client{t: tt, handler: r}
+	// 请求 A 读取会话后, 同一 cookie 的请求 B 完成读取和保存, A 再保存
+	var inner *httptest.ResponseRecorder
+	r.GET("/slow", func(c *wf.Context) {
+		s := Default(c)
+		s.Set("a", "1")
+		inner = cl.get("/set?user=b")
+		if err := s.Save(); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	cl.get("/set?user=a")
+	id := cl.cookie.Value
+	cl.get("/slow")
+	require.Equal(tt, "ok", inner.Body.String())
+	require.Equal(tt, id, inner.Result().Cookies()[0].Value)
+	require.Equal(tt, id, cl.cookie.Value)
+
+	// 后保存的 A 覆盖 B, 会话仍然有效, 旧版本的数据已删除
+	w := cl.get("/get")
+	require.Equal(tt, "a [welcome] false", w.Body.String())
+	require.Equal(tt, id, cl.cookie.Value)
+	require.Len(tt, backend.data, 2)
+}
+
+func TestCSRFStore(tt *testing.T) {
+	r := wf.New()
+	r.Use(Sessions("sid", NewMemoryStore()), wf.CSRF(wf.CSRFConfig{Store: CSRFStore()}))
+	r.GET("/form", func(c *wf.Context) { c.String(http.StatusOK, wf.CSRFToken(c)) })
+	r.POST("/form", func(c *wf.Context) { c.String(http.StatusOK, "ok") })
+
+	cl := &client{t: tt, handler: r}
+	token := cl.get("/form").Body.String()
+	require.NotNil(tt, cl.cookie)
+	require.NotEqual(tt, token, cl.get("/form").Body.String())
+
+	post := func(token string) int {
+		req := httptest.NewRequest("POST", "/form", nil)
+		req.Header.Set("X-CSRF-Token", token)
+		req.AddCookie(cl.cookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	require.Equal(tt, http.StatusOK, post(token))
+	require.Equal(tt, http.StatusForbidden, post("invalid"))
+}
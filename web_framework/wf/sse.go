@@ -0,0 +1,136 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const sseContentType = "text/event-stream"
+
+// ServerSentEvent 为 text/event-stream 中的一个事件
+// Data 为 string 或 []byte 时原样输出, 其他类型编码为 JSON, 多行数据拆分为多个 data 字段
+type ServerSentEvent struct {
+	ID    string
+	Event string
+	// Retry 大于 0 时通知客户端断线重连的等待时间, 精度为毫秒
+	Retry time.Duration
+	Data  interface{}
+}
+
+var _ Render = ServerSentEvent{}
+
+func (e ServerSentEvent) Render(w http.ResponseWriter) error {
+	e.WriteContentType(w)
+	b, err := e.encode()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (e ServerSentEvent) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Content-Type", sseContentType)
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "no-cache")
+	}
+}
+
+func (e ServerSentEvent) encode() ([]byte, error) {
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		data = string(b)
+	}
+
+	var buf bytes.Buffer
+	// id 中不能有 NUL, id 和 event 都不能换行, 否则会破坏事件边界
+	if e.ID != "" {
+		buf.WriteString("id: ")
+		buf.WriteString(sseFieldReplacer.Replace(strings.ReplaceAll(e.ID, "\x00", "")))
+		buf.WriteByte('\n')
+	}
+	if e.Event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(sseFieldReplacer.Replace(e.Event))
+		buf.WriteByte('\n')
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+var sseFieldReplacer = strings.NewReplacer("\r\n", "", "\r", "", "\n", "")
+
+// SSEvent 写出名为 name 的事件并立即 Flush
+func (c *Context) SSEvent(name string, data interface{}) {
+	c.SSE(ServerSentEvent{Event: name, Data: data})
+}
+
+// SSE 写出 event 并立即 Flush
+func (c *Context) SSE(event ServerSentEvent) {
+	c.Render(http.StatusOK, event)
+	c.Writer.Flush()
+}
+
+// SSEStream 将 events 中的事件依次写给客户端, 直到 events 被关闭或客户端断开
+// heartbeat 大于 0 时, 超过 heartbeat 没有事件会写出注释行, 防止连接被代理当作空闲断开
+// 返回值表示客户端是否已断开
+func (c *Context) SSEStream(events <-chan ServerSentEvent, heartbeat time.Duration) bool {
+	ServerSentEvent{}.WriteContentType(c.Writer)
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	done := c.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSE(event)
+			if c.IsAborted() {
+				return false
+			}
+			if ticker != nil {
+				ticker.Reset(heartbeat)
+			}
+		case <-tick:
+			if _, err := c.Writer.WriteString(":\n\n"); err != nil {
+				return true
+			}
+			c.Writer.Flush()
+		}
+	}
+}
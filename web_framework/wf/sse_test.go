@@ -0,0 +1,102 @@
+package wf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSentEventEncode(t *testing.T) {
+	b, err := ServerSentEvent{ID: "1\n2", Event: "progress", Retry: 1500 * time.Millisecond, Data: "a\r\nb\nc"}.encode()
+	require.NoError(t, err)
+	require.Equal(t, "id: 12\nevent: progress\nretry: 1500\ndata: a\ndata: b\ndata: c\n\n", string(b))
+
+	b, err = ServerSentEvent{Data: H{"n": 1}}.encode()
+	require.NoError(t, err)
+	require.Equal(t, "data: {\"n\":1}\n\n", string(b))
+
+	_, err = ServerSentEvent{Data: make(chan int)}.encode()
+	require.Error(t, err)
+}
+
+func TestStream(t *testing.T) {
+	r := New()
+	var steps int
+	var disconnected bool
+	r.GET("/stream", func(c *Context) {
+		steps = 0
+		disconnected = c.Stream(func(w io.Writer) bool {
+			steps++
+			fmt.Fprintf(w, "%d;", steps)
+			return steps < 3
+		})
+	})
+
+	w := performRequest(r, "GET", "/stream")
+	require.Equal(t, "1;2;3;", w.Body.String())
+	require.True(t, w.Flushed)
+	require.False(t, disconnected)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.GET("/forever", func(c *Context) {
+		steps = 0
+		disconnected = c.Stream(func(w io.Writer) bool {
+			steps++
+			if steps == 2 {
+				cancel()
+			}
+			return true
+		})
+	})
+	req := httptest.NewRequest("GET", "/forever", nil).WithContext(ctx)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, 2, steps)
+	require.True(t, disconnected)
+}
+
+func TestSSEvent(t *testing.T) {
+	r := New()
+	r.GET("/events", func(c *Context) {
+		c.SSEvent("message", "hello")
+		c.SSE(ServerSentEvent{ID: "2", Data: []string{"a"}})
+	})
+
+	w := performRequest(r, "GET", "/events")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	require.Equal(t, "event: message\ndata: hello\n\nid: 2\ndata: [\"a\"]\n\n", w.Body.String())
+}
+
+func TestSSEStream(t *testing.T) {
+	r := New()
+	events := make(chan ServerSentEvent)
+	var disconnected bool
+	r.GET("/events", func(c *Context) {
+		disconnected = c.SSEStream(events, 5*time.Millisecond)
+	})
+
+	go func() {
+		events <- ServerSentEvent{Event: "start"}
+		time.Sleep(30 * time.Millisecond)
+		close(events)
+	}()
+	w := performRequest(r, "GET", "/events")
+	require.False(t, disconnected)
+	require.Contains(t, w.Body.String(), "event: start\ndata: \n\n")
+	require.Contains(t, w.Body.String(), ":\n\n:\n\n")
+
+	events = make(chan ServerSentEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	require.True(t, disconnected)
+}
@@ -0,0 +1,270 @@
+package wf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StaticConfig 配置静态文件服务
+type StaticConfig struct {
+	// Index 为目录的默认文件, 默认为 index.html
+	Index string
+	// Browse 为 true 时, 没有 Index 文件的目录返回文件列表, 否则返回 404
+	Browse bool
+	// CacheControl 按顺序匹配, 第一条命中的规则决定 Cache-Control 头
+	CacheControl []CacheRule
+}
+
+// CacheRule 的 Pattern 为 path.Match 模式, 不含 '/' 时匹配文件名, 否则匹配相对于根目录的路径
+// eg: {Pattern: "*.js", Value: "public, max-age=31536000, immutable"}
+type CacheRule struct {
+	Pattern string
+	Value   string
+}
+
+// Static 将 root 目录挂载到 relativePath 下
+func (group *RouterGroup) Static(relativePath, root string) {
+	group.StaticWithConfig(relativePath, os.DirFS(root), StaticConfig{})
+}
+
+// StaticFS 将 fsys 挂载到 relativePath 下, 可用于 embed.FS
+func (group *RouterGroup) StaticFS(relativePath string, fsys fs.FS) {
+	group.StaticWithConfig(relativePath, fsys, StaticConfig{})
+}
+
+func (group *RouterGroup) StaticWithConfig(relativePath string, fsys fs.FS, conf StaticConfig) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static folder")
+	}
+	handler := newStaticServer(fsys, conf).handler("")
+	group.GET(relativePath, handler)
+	group.GET(joinPath(relativePath, "/*filepath"), handler)
+}
+
+// StaticFile 将单个文件注册到 relativePath
+func (group *RouterGroup) StaticFile(relativePath, file string) {
+	group.StaticFileWithConfig(relativePath, file, StaticConfig{})
+}
+
+func (group *RouterGroup) StaticFileWithConfig(relativePath, file string, conf StaticConfig) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static file")
+	}
+	s := newStaticServer(os.DirFS(filepath.Dir(file)), conf)
+	group.GET(relativePath, s.handler(filepath.Base(file)))
+}
+
+type staticServer struct {
+	fsys   fs.FS
+	index  string
+	browse bool
+	rules  []CacheRule
+	// etags 缓存 ModTime 为零值(如 embed.FS)的文件的内容哈希
+	etags sync.Map
+}
+
+func newStaticServer(fsys fs.FS, conf StaticConfig) *staticServer {
+	for _, rule := range conf.CacheControl {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			panic("Invalid cache rule pattern: " + rule.Pattern)
+		}
+	}
+	s := &staticServer{fsys: fsys, index: conf.Index, browse: conf.Browse, rules: conf.CacheControl}
+	if s.index == "" {
+		s.index = "index.html"
+	}
+	return s
+}
+
+// handler 在 file 为空时从 filepath 参数取文件名, 否则总是返回 file
+func (s *staticServer) handler(file string) HandlerFunc {
+	return func(c *Context) {
+		name := file
+		if name == "" {
+			name = strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+			if name == "" {
+				name = "."
+			}
+		}
+		s.serve(c, name)
+	}
+}
+
+func (s *staticServer) serve(c *Context, name string) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		serveFileError(c, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		serveFileError(c, err)
+		return
+	}
+	if !info.IsDir() {
+		s.serveFile(c, name, f, info)
+		return
+	}
+
+	// 目录需要以 '/' 结尾, 否则页面中的相对链接会指向上一级
+	if p := c.Request.URL.Path; !strings.HasSuffix(p, "/") {
+		if q := c.Request.URL.RawQuery; q != "" {
+			p += "/?" + q
+		} else {
+			p += "/"
+		}
+		c.Redirect(http.StatusMovedPermanently, p)
+		return
+	}
+
+	index := path.Join(name, s.index)
+	if ff, err := s.fsys.Open(index); err == nil {
+		defer ff.Close()
+		if fi, err := ff.Stat(); err == nil && !fi.IsDir() {
+			s.serveFile(c, index, ff, fi)
+			return
+		}
+	}
+	if !s.browse {
+		serveFileError(c, fs.ErrNotExist)
+		return
+	}
+	s.listDir(c, name)
+}
+
+// serveFile 交给 http.ServeContent 处理 Range、If-None-Match、If-Modified-Since 等条件请求
+func (s *staticServer) serveFile(c *Context, name string, f fs.File, info fs.FileInfo) {
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			serveFileError(c, err)
+			return
+		}
+		rs = bytes.NewReader(data)
+	}
+
+	header := c.Writer.Header()
+	etag, err := s.etag(name, info, rs)
+	if err != nil {
+		serveFileError(c, err)
+		return
+	}
+	header.Set("ETag", etag)
+	if cc := s.cacheControl(name); cc != "" {
+		header.Set("Cache-Control", cc)
+	}
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), rs)
+}
+
+// etag 优先使用修改时间和大小, 没有修改时间时使用内容哈希
+func (s *staticServer) etag(name string, info fs.FileInfo, rs io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return modTimeETag(info), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha1.New()
+	if _, err := io.Copy(h, rs); err != nil {
+		return "", err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:10]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+func (s *staticServer) cacheControl(name string) string {
+	for _, rule := range s.rules {
+		target := name
+		if !strings.Contains(rule.Pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(rule.Pattern, target); ok {
+			return rule.Value
+		}
+	}
+	return ""
+}
+
+func (s *staticServer) listDir(c *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		serveFileError(c, err)
+		return
+	}
+
+	var b strings.Builder
+	title := html.EscapeString(c.Request.URL.Path)
+	fmt.Fprintf(&b, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<title>Index of %s</title>\n<h1>Index of %s</h1>\n<pre>\n", title, title)
+	if name != "." {
+		b.WriteString("<a href=\"../\">../</a>\n")
+	}
+	for _, entry := range entries {
+		n := entry.Name()
+		if entry.IsDir() {
+			n += "/"
+		}
+		// url.URL 会为包含 ':' 的名字加上 "./", 避免被当作协议
+		u := url.URL{Path: n}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(n))
+	}
+	b.WriteString("</pre>\n")
+
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString(b.String()); err != nil {
+		panic(err)
+	}
+}
+
+// modTimeETag 根据修改时间和大小生成 ETag
+func modTimeETag(info fs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func serveFileError(c *Context, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		serveNoRoute(c)
+	case errors.Is(err, fs.ErrPermission):
+		c.String(http.StatusForbidden, "403 FORBIDDEN: %s\n", c.Path)
+	default:
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "500 INTERNAL SERVER ERROR: %s\n", c.Path)
+	}
+}
+
+// serveNoRoute 在当前 Context 中执行 Engine.NoRoute 设置的处理函数, 使文件不存在时与没有匹配路由一样响应
+// 全局中间件和分组中间件在进入文件处理函数前已经执行, 不再重复
+func serveNoRoute(c *Context) {
+	handlers := HandlersChain{defaultNoRoute}
+	if c.engine != nil && len(c.engine.noRoute) > 0 {
+		handlers = c.engine.noRoute
+	}
+	outer, index := c.handlers, c.index
+	c.handlers, c.index = handlers, -1
+	c.Next()
+	aborted := c.IsAborted()
+	c.handlers, c.index = outer, index
+	if aborted {
+		c.Abort()
+	}
+}
@@ -0,0 +1,137 @@
+package wf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatic(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "css", "vendor"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "index.html"), []byte("<h1>home</h1>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "css", "vendor", "a.css"), []byte("body{}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "readme.txt"), []byte("0123456789"), 0o644))
+
+	r := New()
+	r.Group("/assets").Static("/", root)
+
+	w := performRequest(r, "GET", "/assets/css/vendor/a.css")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "body{}", w.Body.String())
+	require.Contains(t, w.Header().Get("Content-Type"), "text/css")
+	require.NotEmpty(t, w.Header().Get("ETag"))
+	require.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+	w = performRequest(r, "GET", "/assets")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/assets/", w.Header().Get("Location"))
+	w = performRequest(r, "GET", "/assets/")
+	require.Equal(t, "<h1>home</h1>", w.Body.String())
+
+	w = performRequest(r, "GET", "/assets/docs/")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = performRequest(r, "GET", "/assets/missing.js")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = performRequest(r, "GET", "/assets/../static_test.go")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = performRequest(r, "HEAD", "/assets/docs/readme.txt")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "10", w.Header().Get("Content-Length"))
+	require.Empty(t, w.Body.String())
+
+	// 文件不存在时与没有匹配路由一样执行 NoRoute
+	r.NoRoute(func(c *Context) {
+		c.SetHeader("X-Not-Found", "1")
+		c.Next()
+	}, func(c *Context) { c.String(http.StatusNotFound, "custom 404") })
+	for _, path := range []string{"/assets/missing.js", "/missing"} {
+		w = performRequest(r, "GET", path)
+		require.Equal(t, http.StatusNotFound, w.Code, path)
+		require.Equal(t, "custom 404", w.Body.String(), path)
+		require.Equal(t, "1", w.Header().Get("X-Not-Found"), path)
+	}
+}
+
+func TestStaticConditionalAndRange(t *testing.T) {
+	fsys := fstest.MapFS{"data.txt": {Data: []byte("0123456789")}}
+	r := New()
+	r.StaticFS("/fs", fsys)
+
+	w := performRequest(r, "GET", "/fs/data.txt")
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Empty(t, w.Header().Get("Last-Modified"))
+
+	req := httptest.NewRequest("GET", "/fs/data.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/fs/data.txt", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "234", w.Body.String())
+	require.Equal(t, "bytes 2-4/10", w.Header().Get("Content-Range"))
+}
+
+func TestStaticBrowseAndCacheControl(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":         {Data: []byte("js")},
+		"img/logo.png":   {Data: []byte("png")},
+		"img/<b>.txt":    {Data: []byte("x")},
+		"pages/doc.html": {Data: []byte("doc")},
+	}
+	r := New()
+	r.StaticWithConfig("/public", fsys, StaticConfig{
+		Browse: true,
+		CacheControl: []CacheRule{
+			{Pattern: "img/*", Value: "public, max-age=86400"},
+			{Pattern: "*.js", Value: "no-cache"},
+		},
+	})
+
+	w := performRequest(r, "GET", "/public/img/")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `<a href="logo.png">logo.png</a>`)
+	require.Contains(t, w.Body.String(), `&lt;b&gt;.txt`)
+	require.Contains(t, w.Body.String(), `<a href="../">`)
+
+	w = performRequest(r, "GET", "/public/img/logo.png")
+	require.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+	w = performRequest(r, "GET", "/public/app.js")
+	require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	w = performRequest(r, "GET", "/public/pages/doc.html")
+	require.Empty(t, w.Header().Get("Cache-Control"))
+
+	require.Panics(t, func() {
+		r.StaticWithConfig("/bad", fsys, StaticConfig{CacheControl: []CacheRule{{Pattern: "[", Value: "x"}}})
+	})
+	require.Panics(t, func() { r.Static("/files/:name", ".") })
+}
+
+func TestStaticFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "robots.txt")
+	require.NoError(t, os.WriteFile(file, []byte("User-agent: *"), 0o644))
+
+	r := New()
+	r.StaticFile("/robots.txt", file)
+	r.StaticFile("/missing.txt", filepath.Join(t.TempDir(), "missing.txt"))
+
+	w := performRequest(r, "GET", "/robots.txt")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "User-agent: *", w.Body.String())
+	w = performRequest(r, "GET", "/missing.txt")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
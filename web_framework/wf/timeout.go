@@ -0,0 +1,203 @@
+package wf
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeoutOptions 配置超时中间件
+type TimeoutOptions struct {
+	// StatusCode 为超时响应的状态码, 默认为 503, 网关类服务可以使用 504
+	StatusCode int
+	// Response 写出超时响应, 为空时写出状态码和状态文本
+	Response HandlerFunc
+}
+
+// Timeout 返回超时中间件, 后续的处理函数在新的 goroutine 中运行, 请求的 Context 带有截止时间
+// 处理函数的输出先缓冲, 按时完成时再写出; 超时后写出超时响应, 并将 http.ErrHandlerTimeout 记录到 c.Errors,
+// 处理函数之后的写入返回 http.ErrHandlerTimeout 并被丢弃
+// 处理函数使用的是 Context 的副本, 超时后不能再依赖原 Context, 也不能使用 Hijack 和 Flush
+func Timeout(d time.Duration, opts TimeoutOptions) HandlerFunc {
+	if d <= 0 {
+		panic("Invalid timeout: " + d.String())
+	}
+	if opts.StatusCode == 0 {
+		opts.StatusCode = http.StatusServiceUnavailable
+	}
+	if opts.Response == nil {
+		opts.Response = func(c *Context) {
+			c.String(opts.StatusCode, "%d %s\n", opts.StatusCode, strings.ToUpper(http.StatusText(opts.StatusCode)))
+		}
+	}
+
+	return func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		tw := &timeoutWriter{ctx: ctx, header: c.Writer.Header().Clone(), status: c.Writer.Status(), size: noWritten}
+		tc := c.Copy()
+		tc.Request = c.Request.WithContext(ctx)
+		tc.Writer = tw
+		tc.handlers = c.handlers
+		tc.index = c.index
+
+		finish := make(chan interface{}, 1)
+		go func() {
+			defer func() { finish <- recover() }()
+			tc.Next()
+		}()
+
+		select {
+		case p := <-finish:
+			if p != nil {
+				panic(p)
+			}
+			// 处理函数已经返回, tc 不再被其他 goroutine 使用
+			// 截止时间之后的写入已经被拒绝时, 按超时处理
+			if !tw.timeout(false) {
+				c.mu.Lock()
+				c.Keys = tc.Keys
+				c.mu.Unlock()
+				c.Errors = tc.Errors
+				c.StatusCode = tc.StatusCode
+				c.index = tc.index
+				tw.writeTo(c.Writer)
+				return
+			}
+		case <-ctx.Done():
+			tw.timeout(true)
+		}
+
+		c.Abort()
+		if ctx.Err() != context.DeadlineExceeded {
+			// 客户端已经断开, 不需要响应
+			c.Error(ctx.Err())
+			return
+		}
+		c.Error(http.ErrHandlerTimeout)
+		opts.Response(c)
+	}
+}
+
+// timeoutWriter 缓冲处理函数的响应, 超时后丢弃所有写入
+type timeoutWriter struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	header   http.Header
+	buf      []byte
+	status   int
+	size     int
+	timedOut bool
+}
+
+var _ ResponseWriter = &timeoutWriter{}
+
+// Header 返回处理函数自己的响应头, 按时完成时才复制到真正的响应中
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if code > 0 && w.size == noWritten {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size == noWritten {
+		w.size = 0
+	}
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 处理函数可能先于中间件观察到截止时间, 此时也拒绝写入
+	if w.timedOut || w.ctx.Err() != nil {
+		w.timedOut = true
+		w.buf = nil
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.size == noWritten {
+		w.size = 0
+	}
+	w.buf = append(w.buf, data...)
+	w.size += len(data)
+	return len(data), nil
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+func (w *timeoutWriter) Written() bool {
+	return w.Size() != noWritten
+}
+
+// Flush 不做任何事, 缓冲的输出在处理函数返回后一次写出
+func (w *timeoutWriter) Flush() {}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *timeoutWriter) CloseNotify() <-chan bool {
+	return nil
+}
+
+func (w *timeoutWriter) Push(string, *http.PushOptions) error {
+	return http.ErrNotSupported
+}
+
+// timeout 返回是否已经超时, force 为 true 时标记为超时并丢弃缓冲的数据
+func (w *timeoutWriter) timeout(force bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if force {
+		w.timedOut = true
+		w.buf = nil
+	}
+	return w.timedOut
+}
+
+// writeTo 将缓冲的响应头、状态码和 body 写到 dst
+func (w *timeoutWriter) writeTo(dst ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	header := dst.Header()
+	for k := range header {
+		if _, ok := w.header[k]; !ok {
+			delete(header, k)
+		}
+	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+	dst.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		dst.Write(w.buf)
+	} else if w.size != noWritten {
+		dst.WriteHeaderNow()
+	}
+}
@@ -0,0 +1,100 @@
+package wf
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	var errs Errors
+	var keys map[string]interface{}
+	r := New()
+	r.Use(func(c *Context) {
+		c.Writer.Header().Set("X-Outer", "1")
+		c.Next()
+		errs = c.Errors
+		keys = c.Keys
+	})
+	r.Use(Timeout(50*time.Millisecond, TimeoutOptions{}))
+	r.GET("/fast", func(c *Context) {
+		_, ok := c.Request.Context().Deadline()
+		c.Set("deadline", ok)
+		c.Set("user", "geek")
+		c.Writer.Header().Del("X-Outer")
+		c.Error(errors.New("fast"))
+		c.String(http.StatusCreated, "fast")
+	}, func(c *Context) {
+		c.Writer.Header().Set("X-After", "1")
+	})
+	r.GET("/abort", func(c *Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}, func(c *Context) {
+		c.String(http.StatusOK, "unreachable")
+	})
+
+	w := performRequest(r, "GET", "/fast")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "fast", w.Body.String())
+	require.Empty(t, w.Header().Get("X-Outer"))
+	require.Equal(t, "1", w.Header().Get("X-After"))
+	require.Equal(t, true, keys["deadline"])
+	require.Equal(t, "geek", keys["user"])
+	require.Len(t, errs, 1)
+
+	w = performRequest(r, "GET", "/abort")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Body.String())
+}
+
+func TestTimeoutExpired(t *testing.T) {
+	var errs Errors
+	late := make(chan error, 1)
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.GET("/slow", Timeout(20*time.Millisecond, TimeoutOptions{}), func(c *Context) {
+		c.Writer.Header().Set("X-Slow", "1")
+		c.String(http.StatusOK, "partial")
+		<-c.Request.Context().Done()
+		// 超时后的写入被丢弃
+		_, err := c.Writer.Write([]byte("late"))
+		c.Set("late", true)
+		late <- err
+	})
+	r.GET("/gateway", Timeout(20*time.Millisecond, TimeoutOptions{
+		StatusCode: http.StatusGatewayTimeout,
+		Response:   func(c *Context) { c.JSON(http.StatusGatewayTimeout, H{"error": "timeout"}) },
+	}), func(c *Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "too late")
+	})
+
+	w := performRequest(r, "GET", "/slow")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "503 SERVICE UNAVAILABLE\n", w.Body.String())
+	require.Empty(t, w.Header().Get("X-Slow"))
+	require.Len(t, errs, 1)
+	require.Equal(t, http.ErrHandlerTimeout, errs[0].Err)
+	require.Equal(t, http.ErrHandlerTimeout, <-late)
+	require.Equal(t, "503 SERVICE UNAVAILABLE\n", w.Body.String())
+
+	w = performRequest(r, "GET", "/gateway")
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+	require.JSONEq(t, `{"error":"timeout"}`, w.Body.String())
+
+	require.Panics(t, func() { Timeout(0, TimeoutOptions{}) })
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	r := New()
+	r.Use(Recovery(), Timeout(time.Second, TimeoutOptions{}))
+	r.GET("/panic", func(c *Context) { panic("boom") })
+	w := performRequest(r, "GET", "/panic")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -2,79 +2,313 @@ package wf
 
 import "strings"
 
+type nodeType uint8
+
+const (
+	static nodeType = iota
+	param
+	catchAll
+)
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 按路由中出现的顺序保存路径参数, 查找时复用同一块底层数组
+type Params []Param
+
+func (ps Params) Get(name string) (string, bool) {
+	for i := range ps {
+		if ps[i].Key == name {
+			return ps[i].Value, true
+		}
+	}
+	return "", false
+}
+
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
+// node 为压缩前缀树(radix tree)的节点
+// static 节点的 prefix 为路径片段, param/catchAll 节点的 prefix 为参数名
+// 匹配优先级: static > 带约束的 param > param > catchAll, 匹配失败时回溯
+// 带约束的 param 之间按注册顺序匹配
 type node struct {
-	part     string
-	isWild   bool
-	children []*node
+	prefix       string
+	nType        nodeType
+	indices      string //static 子节点的首字节
+	children     []*node
+	wildChildren []*node
+	catchChild   *node
+	//param 约束, eg: :id<int> :name<[a-z]+\.txt>
+	constraint string
+	match      func(string) bool
 	//叶子节点
 	path     string
+	name     string
 	handlers HandlersChain
 }
 
-func (n *node) insert(path string, parts []string, handlers HandlersChain, height int) {
-	if len(parts) == height {
-		n.path = path
-		n.handlers = handlers
-		return
+// segment 为路由模式拆分后的插入单元, param 的 text 包含约束, eg: id<int>
+type segment struct {
+	text  string
+	nType nodeType
+}
+
+// parseSegments 将 parsePath 得到的 parts 转换为 static/param/catchAll 片段
+// eg: /:last/ok/*path -> "/" :last "/ok/" *path
+func parseSegments(parts []string) []segment {
+	segments := make([]segment, 0, len(parts)+1)
+	text := "/"
+	for _, part := range parts {
+		switch part[0] {
+		case ':':
+			segments = append(segments, segment{text, static}, segment{part[1:], param})
+			text = "/"
+		case '*':
+			segments = append(segments, segment{text, static}, segment{part[1:], catchAll})
+			text = "/"
+		default:
+			text += part + "/"
+		}
+	}
+	if len(segments) == 0 {
+		if len(text) > 1 {
+			text = text[:len(text)-1]
+		}
+		return append(segments, segment{text, static})
+	}
+	if len(text) > 1 {
+		segments = append(segments, segment{text[:len(text)-1], static})
+	}
+	return segments
+}
+
+func (n *node) insert(path string, segments []segment, handlers HandlersChain) *node {
+	for _, seg := range segments {
+		switch seg.nType {
+		case static:
+			n = n.addStatic(seg.text)
+		case param:
+			n = n.addWild(seg.text)
+		case catchAll:
+			if n.catchChild == nil {
+				n.catchChild = &node{prefix: seg.text, nType: catchAll}
+			}
+			n = n.catchChild
+		}
+	}
+	n.path = path
+	n.handlers = handlers
+	return n
+}
+
+// addStatic 在 n 的子节点中插入静态片段 s, 返回 s 结束处的节点
+func (n *node) addStatic(s string) *node {
+	for {
+		idx := strings.IndexByte(n.indices, s[0])
+		if idx < 0 {
+			child := &node{prefix: s}
+			n.indices += s[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[idx]
+		i := commonPrefix(s, child.prefix)
+		if i < len(child.prefix) {
+			child.split(i)
+		}
+		if i == len(s) {
+			return child
+		}
+		s = s[i:]
+		n = child
+	}
+}
+
+// addWild 插入 param 节点, 带约束的节点排在无约束的节点之前
+func (n *node) addWild(text string) *node {
+	name, constraint := splitConstraint(text)
+	for _, child := range n.wildChildren {
+		if child.prefix == name && child.constraint == constraint {
+			return child
+		}
+	}
+
+	child := &node{prefix: name, nType: param, constraint: constraint}
+	if constraint == "" {
+		n.wildChildren = append(n.wildChildren, child)
+		return child
+	}
+	child.match = compileConstraint(constraint)
+	i := 0
+	for i < len(n.wildChildren) && n.wildChildren[i].constraint != "" {
+		i++
+	}
+	n.wildChildren = append(n.wildChildren, nil)
+	copy(n.wildChildren[i+1:], n.wildChildren[i:])
+	n.wildChildren[i] = child
+	return child
+}
+
+// split 在 i 处拆分 static 节点, 原有的子节点和叶子信息下移
+func (n *node) split(i int) {
+	child := *n
+	child.prefix = n.prefix[i:]
+	*n = node{
+		prefix:   n.prefix[:i],
+		indices:  child.prefix[:1],
+		children: []*node{&child},
+	}
+}
+
+// conflict 判断 segments 是否与已有路由重复, 同一位置的参数名不同也视为重复
+// eg:
+// /h1/:name -> /h1/name yes
+// /h1/:name -> /h1/*path yes
+// /h1/:name -> /h1/:id<int> yes
+// /h1/:name -> /h1/:id no
+func (n *node) conflict(segments []segment) bool {
+	if len(segments) == 0 {
+		return n.path != ""
 	}
 
-	part := parts[height]
-	child := n.matchChild(part, false)
-	if child == nil {
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+	seg, rest := segments[0], segments[1:]
+	switch seg.nType {
+	case static:
+		s := seg.text
+		for {
+			idx := strings.IndexByte(n.indices, s[0])
+			if idx < 0 {
+				return false
+			}
+			n = n.children[idx]
+			if !strings.HasPrefix(s, n.prefix) {
+				return false
+			}
+			s = s[len(n.prefix):]
+			if s == "" {
+				return n.conflict(rest)
+			}
+		}
+	case param:
+		_, constraint := splitConstraint(seg.text)
+		for _, child := range n.wildChildren {
+			if child.constraint == constraint && child.conflict(rest) {
+				return true
+			}
+		}
+		return false
+	default:
+		return n.catchChild != nil && n.catchChild.conflict(rest)
 	}
-	child.insert(path, parts, handlers, height+1)
 }
 
-func (n *node) search(parts []string, height int) *node {
-	if len(parts) == height || (len(n.part) != 0 && n.part[0] == '*') {
+// search 匹配 path, 命中时参数追加到 params 中, 不分配内存
+func (n *node) search(path string, params *Params) *node {
+	switch n.nType {
+	case static:
+		if len(path) < len(n.prefix) || path[:len(n.prefix)] != n.prefix {
+			return nil
+		}
+		path = path[len(n.prefix):]
+	case param:
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 || (n.match != nil && !n.match(path[:end])) {
+			return nil
+		}
+		*params = append(*params, Param{Key: n.prefix, Value: path[:end]})
+		path = path[end:]
+	case catchAll:
+		if path == "" {
+			return nil
+		}
+		*params = append(*params, Param{Key: n.prefix, Value: path})
+		return n
+	}
+
+	if path == "" {
 		if n.path == "" {
 			return nil
 		}
 		return n
 	}
 
-	var ret *node = nil
-	part := parts[height]
-	children := n.matchChildren(part)
-	for _, child := range children {
-		res := child.search(parts, height+1)
-		if res != nil {
-			pathParts := parsePath(res.path)
-			if strings.Contains(pathParts[height], "*") {
-				if ret == nil {
-					ret = res
-				}
-				continue
-			} else if strings.Contains(pathParts[height], ":") {
-				ret = res
-				continue
-			} else {
-				ret = res
-				break
-			}
+	mark := len(*params)
+	if idx := strings.IndexByte(n.indices, path[0]); idx >= 0 {
+		if res := n.children[idx].search(path, params); res != nil {
+			return res
+		}
+		*params = (*params)[:mark]
+	}
+	for _, child := range n.wildChildren {
+		if res := child.search(path, params); res != nil {
+			return res
 		}
+		*params = (*params)[:mark]
 	}
-	return ret
+	if n.catchChild != nil {
+		if res := n.catchChild.search(path, params); res != nil {
+			return res
+		}
+		*params = (*params)[:mark]
+	}
+	return nil
 }
 
-func (n *node) matchChild(part string, wild bool) (n_ret *node) {
+// walk 按深度优先遍历所有叶子节点
+func (n *node) walk(fn func(*node)) {
+	if n.path != "" {
+		fn(n)
+	}
 	for _, child := range n.children {
-		if child.part == part {
-			return child
-		} else if wild && child.isWild {
-			n_ret = child
+		child.walk(fn)
+	}
+	for _, child := range n.wildChildren {
+		child.walk(fn)
+	}
+	if n.catchChild != nil {
+		n.catchChild.walk(fn)
+	}
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// cleanPath 去掉重复和末尾的 '/', 只有路径不规范时才分配内存
+func cleanPath(path string) string {
+	if path == "" || path == "/" {
+		return "/"
+	}
+	if path[0] == '/' && !strings.Contains(path, "//") {
+		if path[len(path)-1] == '/' {
+			return strings.TrimRight(path, "/")
 		}
+		return path
 	}
-	return n_ret
+	return "/" + strings.Join(splitPath(path), "/")
 }
 
-func (n *node) matchChildren(part string) (ns []*node) {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			ns = append(ns, child)
+// splitPath 按 '/' 拆分并去掉空片段, 不做通配符处理
+func splitPath(path string) []string {
+	vs := strings.Split(path, "/")
+	parts := make([]string, 0, len(vs))
+	for _, item := range vs {
+		if item != "" {
+			parts = append(parts, item)
 		}
 	}
-	return ns
+	return parts
 }
@@ -0,0 +1,165 @@
+package wf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSegments(t *testing.T) {
+	require.Equal(t, []segment{{"/", static}}, parseSegments(parsePath("/")))
+	require.Equal(t, []segment{{"/a/b", static}}, parseSegments(parsePath("/a/b/")))
+	require.Equal(t, []segment{{"/p/", static}, {"name", param}}, parseSegments(parsePath("/p/:name")))
+	require.Equal(t, []segment{
+		{"/", static}, {"last", param}, {"/ok/", static}, {"path", catchAll},
+	}, parseSegments(parsePath("/:last/ok/*path")))
+}
+
+func TestCleanPath(t *testing.T) {
+	require.Equal(t, "/", cleanPath(""))
+	require.Equal(t, "/", cleanPath("//"))
+	require.Equal(t, "/a/b", cleanPath("/a/b"))
+	require.Equal(t, "/a/b", cleanPath("/a/b//"))
+	require.Equal(t, "/a/b", cleanPath("//a///b"))
+	require.Equal(t, "/a", cleanPath("a"))
+}
+
+func TestTreeSearch(t *testing.T) {
+	r := New()
+	routes := []string{
+		"/",
+		"/hello",
+		"/help",
+		"/hello/:name",
+		"/hello/b/c",
+		"/hi/:name/:action",
+		"/src/*filepath",
+		"/src/static",
+		"/:lang/doc",
+	}
+	for _, route := range routes {
+		r.addRoute("GET", route, nil)
+	}
+
+	tests := []struct {
+		path   string
+		route  string
+		params Params
+	}{
+		{"/", "/", Params{}},
+		{"/hello", "/hello", Params{}},
+		{"/help", "/help", Params{}},
+		{"/hel", "", nil},
+		{"/hello/geek", "/hello/:name", Params{{"name", "geek"}}},
+		{"/hello/b/c", "/hello/b/c", Params{}},
+		{"/hello/b", "/hello/:name", Params{{"name", "b"}}},
+		{"/hello/b/d", "", nil},
+		{"/hi/geek/run", "/hi/:name/:action", Params{{"name", "geek"}, {"action", "run"}}},
+		{"/hi/geek", "", nil},
+		{"/src/static", "/src/static", Params{}},
+		{"/src/static/x.js", "/src/*filepath", Params{{"filepath", "static/x.js"}}},
+		{"/src/", "", nil},
+		{"/en/doc", "/:lang/doc", Params{{"lang", "en"}}},
+		{"/hello/doc", "/hello/:name", Params{{"name", "doc"}}},
+		{"//hello//geek/", "/hello/:name", Params{{"name", "geek"}}},
+	}
+	for _, test := range tests {
+		n, params := r.getRoute("GET", test.path)
+		if test.route == "" {
+			require.Nil(t, n, test.path)
+			continue
+		}
+		require.NotNil(t, n, test.path)
+		require.Equal(t, test.route, n.path, test.path)
+		require.Equal(t, test.params, params, test.path)
+	}
+}
+
+func TestTreeBacktrack(t *testing.T) {
+	r := New()
+	r.addRoute("GET", "/:a/x", nil)
+	r.addRoute("GET", "/:b/y", nil)
+	r.addRoute("GET", "/s/:c/z", nil)
+	r.addRoute("GET", "/*rest", nil)
+
+	n, params := r.getRoute("GET", "/s/y")
+	require.Equal(t, "/:b/y", n.path)
+	require.Equal(t, Params{{"b", "s"}}, params)
+
+	n, params = r.getRoute("GET", "/s/1/w")
+	require.Equal(t, "/*rest", n.path)
+	require.Equal(t, Params{{"rest", "s/1/w"}}, params)
+}
+
+func TestTreeConflict(t *testing.T) {
+	r := New()
+	r.addRoute("GET", "/users/:id", nil)
+	r.addRoute("GET", "/users/:id/posts", nil)
+	r.addRoute("GET", "/users/:name/books", nil)
+	r.addRoute("GET", "/users/*path", nil)
+	r.addRoute("GET", "/user", nil)
+
+	require.Panics(t, func() { r.addRoute("GET", "/users/:uid", nil) })
+	require.Panics(t, func() { r.addRoute("GET", "/users/:uid/posts", nil) })
+	require.Panics(t, func() { r.addRoute("GET", "/users/*file", nil) })
+	require.Panics(t, func() { r.addRoute("GET", "//user/", nil) })
+	require.NotPanics(t, func() { r.addRoute("POST", "/users/:uid", nil) })
+	require.Equal(t, 1, r.maxParams)
+}
+
+var benchRoutes = []string{
+	"/",
+	"/users",
+	"/users/:id",
+	"/users/:id/posts",
+	"/users/:id/posts/:post",
+	"/repos/:owner/:repo/issues",
+	"/repos/:owner/:repo/pulls/:number",
+	"/search/repositories",
+	"/static/*filepath",
+}
+
+func newBenchEngine() *Engine {
+	r := New()
+	for _, route := range benchRoutes {
+		r.addRoute("GET", route, nil)
+	}
+	return r
+}
+
+func benchmarkFindRoute(b *testing.B, path string) {
+	r := newBenchEngine()
+	params := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		params = params[:0]
+		if r.findRoute("GET", path, &params) == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
+
+func BenchmarkFindRouteStatic(b *testing.B) {
+	benchmarkFindRoute(b, "/search/repositories")
+}
+
+func BenchmarkFindRouteParams(b *testing.B) {
+	benchmarkFindRoute(b, "/repos/shui12jiao/goes/pulls/42")
+}
+
+func BenchmarkFindRouteCatchAll(b *testing.B) {
+	benchmarkFindRoute(b, "/static/css/site/main.css")
+}
+
+func TestFindRouteAllocs(t *testing.T) {
+	r := newBenchEngine()
+	params := make(Params, 0, r.maxParams)
+	for _, path := range []string{"/search/repositories", "/repos/a/b/pulls/1", "/static/a/b.css", "/users/1/"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			params = params[:0]
+			r.findRoute("GET", path, &params)
+		})
+		require.Zero(t, allocs, path)
+	}
+}
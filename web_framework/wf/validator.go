@@ -0,0 +1,362 @@
+package wf
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidatorFunc 校验字段值, param 为规则 '=' 后的参数
+type ValidatorFunc func(v reflect.Value, param string) bool
+
+// FieldError 描述一个字段未通过的规则
+type FieldError struct {
+	Field string      `json:"field"`
+	Rule  string      `json:"rule"`
+	Param string      `json:"param,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+func (e FieldError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("field %s failed on rule %s", e.Field, e.Rule)
+	}
+	return fmt.Sprintf("field %s failed on rule %s=%s", e.Field, e.Rule, e.Param)
+}
+
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, e := range ve {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// InvalidRuleError 表示 binding tag 中的规则未注册或参数无效, 是代码的错误而不是请求的错误
+type InvalidRuleError struct {
+	Type  reflect.Type
+	Field string
+	Rule  string
+}
+
+func (e *InvalidRuleError) Error() string {
+	return fmt.Sprintf("invalid validation rule %q on field %s of %s", e.Rule, e.Field, e.Type)
+}
+
+// Validator 根据 `binding:"required,min=1,max=64,email,oneof=a b"` 校验结构体
+// omitempty 使零值跳过后续规则, 嵌套结构体、指针、切片中的结构体会递归校验
+// 每个结构体类型的 tag 在第一次校验时解析并缓存, 规则有误时返回 *InvalidRuleError
+type Validator struct {
+	mu    sync.RWMutex
+	rules map[string]ValidatorFunc
+	// structs 缓存已解析的结构体类型, 解析失败的类型不缓存
+	structs map[reflect.Type][]fieldRules
+}
+
+// fieldRules 为一个字段解析后的规则, rules 为空时只递归校验
+type fieldRules struct {
+	index int
+	name  string
+	rules []fieldRule
+}
+
+type fieldRule struct {
+	name, param string
+}
+
+// numericParamRules 为参数必须是数字的内置规则
+var numericParamRules = map[string]bool{
+	"min": true, "max": true, "len": true, "eq": true, "ne": true, "gt": true, "gte": true, "lt": true, "lte": true,
+}
+
+func NewValidator() *Validator {
+	v := &Validator{rules: make(map[string]ValidatorFunc, len(builtinRules)), structs: make(map[reflect.Type][]fieldRules)}
+	for name, fn := range builtinRules {
+		v.rules[name] = fn
+	}
+	return v
+}
+
+func (v *Validator) RegisterValidation(name string, fn ValidatorFunc) {
+	if name == "" || name == "omitempty" || fn == nil {
+		panic("Invalid validation rule: " + name)
+	}
+	v.mu.Lock()
+	v.rules[name] = fn
+	v.mu.Unlock()
+}
+
+// Validate 返回 ValidationErrors, obj 不是结构体(或其指针)时不做校验
+func (v *Validator) Validate(obj interface{}) error {
+	val := reflect.ValueOf(obj)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if err := v.validateStruct(val, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// structRules 返回 t 各字段的规则, 第一次调用时解析 tag 并缓存
+func (v *Validator) structRules(t reflect.Type) ([]fieldRules, error) {
+	v.mu.RLock()
+	fields, ok := v.structs[t]
+	v.mu.RUnlock()
+	if ok {
+		return fields, nil
+	}
+	fields, err := v.parseStruct(t)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	v.structs[t] = fields
+	v.mu.Unlock()
+	return fields, nil
+}
+
+// parseStruct 解析 t 各字段的 binding tag, 检查规则是否已注册、内置比较规则的参数是否为数字
+func (v *Validator) parseStruct(t reflect.Type) ([]fieldRules, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	fields := make([]fieldRules, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get("binding")
+		if tag == "-" {
+			continue
+		}
+		f := fieldRules{index: i, name: sf.Name}
+		if tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				name, param := rule, ""
+				if i := strings.IndexByte(rule, '='); i >= 0 {
+					name, param = rule[:i], rule[i+1:]
+				}
+				if name != "omitempty" {
+					if _, ok := v.rules[name]; !ok {
+						return nil, &InvalidRuleError{Type: t, Field: sf.Name, Rule: rule}
+					}
+					if _, err := strconv.ParseFloat(param, 64); err != nil && numericParamRules[name] {
+						return nil, &InvalidRuleError{Type: t, Field: sf.Name, Rule: rule}
+					}
+				}
+				f.rules = append(f.rules, fieldRule{name: name, param: param})
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (v *Validator) validateStruct(val reflect.Value, path string, errs *ValidationErrors) error {
+	fields, err := v.structRules(val.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fieldPath := f.name
+		if path != "" {
+			fieldPath = path + "." + f.name
+		}
+		field := val.Field(f.index)
+		if len(f.rules) > 0 && !v.validateField(field, f.rules, fieldPath, errs) {
+			continue
+		}
+		if err := v.validateNested(field, fieldPath, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateField 返回 false 表示字段未通过或因 omitempty 跳过, 不再递归
+func (v *Validator) validateField(field reflect.Value, rules []fieldRule, path string, errs *ValidationErrors) bool {
+	for _, rule := range rules {
+		if rule.name == "omitempty" {
+			if field.IsZero() {
+				return false
+			}
+			continue
+		}
+
+		v.mu.RLock()
+		fn := v.rules[rule.name]
+		v.mu.RUnlock()
+		if !fn(field, rule.param) {
+			*errs = append(*errs, FieldError{Field: path, Rule: rule.name, Param: rule.param, Value: fieldValue(field)})
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Validator) validateNested(field reflect.Value, path string, errs *ValidationErrors) error {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Struct:
+		if field.Type() != timeType {
+			return v.validateStruct(field, path, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if err := v.validateNested(field.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fieldValue(field reflect.Value) interface{} {
+	if field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+	if !field.CanInterface() {
+		return nil
+	}
+	return field.Interface()
+}
+
+var builtinRules = map[string]ValidatorFunc{
+	"required": func(v reflect.Value, _ string) bool { return !v.IsZero() },
+	"min":      func(v reflect.Value, p string) bool { return compareParam(v, p) >= 0 },
+	"max":      func(v reflect.Value, p string) bool { return compareParam(v, p) <= 0 },
+	"len":      func(v reflect.Value, p string) bool { return compareParam(v, p) == 0 },
+	"eq":       func(v reflect.Value, p string) bool { return compareParam(v, p) == 0 },
+	"ne":       func(v reflect.Value, p string) bool { return compareParam(v, p) != 0 },
+	"gt":       func(v reflect.Value, p string) bool { return compareParam(v, p) > 0 },
+	"gte":      func(v reflect.Value, p string) bool { return compareParam(v, p) >= 0 },
+	"lt":       func(v reflect.Value, p string) bool { return compareParam(v, p) < 0 },
+	"lte":      func(v reflect.Value, p string) bool { return compareParam(v, p) <= 0 },
+	"oneof":    isOneOf,
+	"email":    isEmail,
+	"url":      isURL,
+	"alpha":    matchString(regexp.MustCompile(`^[a-zA-Z]+$`)),
+	"alphanum": matchString(regexp.MustCompile(`^[a-zA-Z0-9]+$`)),
+	"numeric":  matchString(regexp.MustCompile(`^[-+]?[0-9]+(?:\.[0-9]+)?$`)),
+	"uuid":     matchString(regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)),
+}
+
+// compareParam 比较字段与参数: 数字比较数值, 字符串比较字符数, 切片和 map 比较长度
+// 字段类型不支持或参数无法解析时返回 -2 使 min/max 等规则失败, 内置规则的参数在解析 tag 时已经检查
+func compareParam(v reflect.Value, param string) int {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return -2
+		}
+		v = v.Elem()
+	}
+
+	var n float64
+	switch v.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return -2
+	}
+
+	p, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return -2
+	}
+	switch {
+	case n < p:
+		return -1
+	case n > p:
+		return 1
+	}
+	return 0
+}
+
+func stringValue(v reflect.Value) (string, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	}
+	return "", false
+}
+
+func isOneOf(v reflect.Value, param string) bool {
+	s, ok := stringValue(v)
+	if !ok {
+		return false
+	}
+	for _, option := range strings.Fields(param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmail(v reflect.Value, _ string) bool {
+	s, ok := stringValue(v)
+	if !ok {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && addr.Name == ""
+}
+
+func isURL(v reflect.Value, _ string) bool {
+	s, ok := stringValue(v)
+	if !ok {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
+
+func matchString(re *regexp.Regexp) ValidatorFunc {
+	return func(v reflect.Value, _ string) bool {
+		s, ok := stringValue(v)
+		return ok && re.MatchString(s)
+	}
+}
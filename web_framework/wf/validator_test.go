@@ -0,0 +1,124 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type validItem struct {
+	SKU string `json:"sku" binding:"required,alphanum"`
+}
+
+type validOrder struct {
+	Name  string      `json:"name" binding:"required,min=1,max=8"`
+	Email string      `json:"email" binding:"omitempty,email"`
+	Kind  string      `json:"kind" binding:"oneof=a b"`
+	Count int         `json:"count" binding:"gte=1,lte=10"`
+	Items []validItem `json:"items" binding:"required"`
+	Ref   *validItem  `json:"ref"`
+	Code  string      `json:"code" binding:"even"`
+}
+
+func TestValidator(t *testing.T) {
+	v := NewValidator()
+	v.RegisterValidation("even", func(v reflect.Value, _ string) bool { return len(v.String())%2 == 0 })
+
+	ok := validOrder{Name: "geek", Kind: "a", Count: 1, Items: []validItem{{"x1"}}, Code: "ab"}
+	require.NoError(t, v.Validate(&ok))
+
+	bad := validOrder{
+		Name:  "toolongname",
+		Email: "not-an-email",
+		Kind:  "c",
+		Count: 11,
+		Items: []validItem{{"ok"}, {"!"}},
+		Ref:   &validItem{},
+		Code:  "abc",
+	}
+	err := v.Validate(bad)
+	var ve ValidationErrors
+	require.True(t, errors.As(err, &ve))
+	require.Equal(t, []FieldError{
+		{Field: "Name", Rule: "max", Param: "8", Value: "toolongname"},
+		{Field: "Email", Rule: "email", Value: "not-an-email"},
+		{Field: "Kind", Rule: "oneof", Param: "a b", Value: "c"},
+		{Field: "Count", Rule: "lte", Param: "10", Value: 11},
+		{Field: "Items[1].SKU", Rule: "alphanum", Value: "!"},
+		{Field: "Ref.SKU", Rule: "required", Value: ""},
+		{Field: "Code", Rule: "even", Value: "abc"},
+	}, []FieldError(ve))
+
+	// tag 有误时返回错误而不是 panic, 注册规则后可以正常校验
+	type unknownRule struct {
+		A string `binding:"required,odd"`
+	}
+	var ire *InvalidRuleError
+	require.True(t, errors.As(v.Validate(unknownRule{}), &ire))
+	require.Equal(t, "A", ire.Field)
+	require.Equal(t, "odd", ire.Rule)
+	v.RegisterValidation("odd", func(v reflect.Value, _ string) bool { return len(v.String())%2 == 1 })
+	require.NoError(t, v.Validate(unknownRule{A: "a"}))
+
+	type badParam struct {
+		N int `binding:"min=abc"`
+	}
+	err = v.Validate(struct{ Items []badParam }{Items: []badParam{{}}})
+	require.True(t, errors.As(err, &ire))
+	require.Equal(t, "min=abc", ire.Rule)
+}
+
+func TestBindValidation(t *testing.T) {
+	r := New()
+	r.RegisterValidation("even", func(v reflect.Value, _ string) bool { return len(v.String())%2 == 0 })
+	r.POST("/orders", func(c *Context) {
+		var o validOrder
+		if c.Bind(&o) == nil {
+			c.String(http.StatusCreated, o.Name)
+		}
+	})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"name":"geek","kind":"b","count":2,"items":[{"sku":"a1"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = post(`{"name":"","kind":"b","count":2,"items":[{"sku":"a1"}],"code":"x"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var body struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	require.NoError(t, json.NewDecoder(strings.NewReader(w.Body.String())).Decode(&body))
+	require.Equal(t, "validation failed", body.Error)
+	require.Len(t, body.Fields, 2)
+	require.Equal(t, "Name", body.Fields[0].Field)
+	require.Equal(t, "required", body.Fields[0].Rule)
+	require.Equal(t, "Code", body.Fields[1].Field)
+	require.Equal(t, "x", body.Fields[1].Value)
+
+	r.POST("/invalid", func(c *Context) {
+		var o struct {
+			Name string `json:"name" binding:"max=ten"`
+		}
+		_ = c.Bind(&o)
+	})
+	req := httptest.NewRequest("POST", "/invalid", strings.NewReader(`{"name":"geek"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,600 @@
+package wf
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型, 与 RFC 6455 的 opcode 相同
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// 关闭码, 见 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	websocketGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultWebSocketLimit   = 1 << 20
+	defaultWebSocketBufSize = 4096
+	maxControlPayload       = 125
+
+	finalBit = 1 << 7
+	rsvBits  = 7 << 4
+	maskBit  = 1 << 7
+)
+
+var (
+	// ErrReadLimit 表示消息超过了读取限制, 连接已发送 1009 关闭帧
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+	// ErrCloseSent 表示已经发送过关闭帧, 不能再写入
+	ErrCloseSent = errors.New("websocket: close sent")
+)
+
+// CloseError 为收到对端关闭帧时 ReadMessage 返回的错误
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError 判断 err 是否为 codes 中任一关闭码的 CloseError
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatCloseMessage 生成关闭帧的内容, CloseNoStatusReceived 对应空内容
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+type WebSocketConfig struct {
+	// CheckOrigin 返回 false 时以 403 拒绝握手, 为空时只接受没有 Origin 或 Origin 与 Host 相同的请求
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols 按优先级排列, 选中第一个客户端也支持的协议
+	Subprotocols []string
+	// ReadLimit 为单条消息的最大字节数, 默认 1 MB
+	ReadLimit int64
+	// WriteBufferSize 为 NextWriter 每个分片的大小, 默认 4096
+	WriteBufferSize int
+	// HandshakeTimeout 为写出握手响应的超时时间, 为 0 时不限制
+	HandshakeTimeout time.Duration
+}
+
+// WebSocketHandler 在握手完成后调用, 返回后连接会被关闭
+type WebSocketHandler func(c *Context, conn *Conn)
+
+// WebSocket 返回处理 WebSocket 握手的 HandlerFunc, 可以和普通路由一样使用中间件
+func WebSocket(handler WebSocketHandler) HandlerFunc {
+	return WebSocketWithConfig(WebSocketConfig{}, handler)
+}
+
+func WebSocketWithConfig(conf WebSocketConfig, handler WebSocketHandler) HandlerFunc {
+	return func(c *Context) {
+		conn, err := c.UpgradeWithConfig(conf)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(c, conn)
+
+		// handler 没有主动关闭时发送正常关闭帧, 对端已不可写时放弃
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = conn.WriteMessage(CloseMessage, FormatCloseMessage(CloseNormalClosure, ""))
+	}
+}
+
+// Upgrade 将请求升级为 WebSocket 连接, 使用默认配置
+func (c *Context) Upgrade() (*Conn, error) {
+	return c.UpgradeWithConfig(WebSocketConfig{})
+}
+
+// UpgradeWithConfig 校验握手请求并接管连接
+// 握手失败时已写出错误响应, 调用者只需返回
+func (c *Context) UpgradeWithConfig(conf WebSocketConfig) (*Conn, error) {
+	r := c.Request
+	fail := func(code int, msg string) (*Conn, error) {
+		err := errors.New("websocket: " + msg)
+		c.AbortWithError(code, err)
+		return nil, err
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return fail(http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+	checkOrigin := conf.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "request origin not allowed")
+	}
+	subprotocol := selectSubprotocol(r, conf.Subprotocols)
+
+	c.Status(http.StatusSwitchingProtocols)
+	netConn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "hijack: "+err.Error())
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	b.WriteString(acceptKey(key))
+	b.WriteString("\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	// 保留中间件设置的响应头, 如 Set-Cookie
+	for k, vs := range c.Writer.Header() {
+		if k == "Upgrade" || k == "Connection" || strings.HasPrefix(k, "Sec-Websocket-") {
+			continue
+		}
+		for _, v := range vs {
+			b.WriteString(k + ": " + strings.NewReplacer("\r", " ", "\n", " ").Replace(v) + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+
+	// 清除 http.Server 设置的超时, 之后由 Conn 的 SetReadDeadline/SetWriteDeadline 控制
+	_ = netConn.SetDeadline(time.Time{})
+	if conf.HandshakeTimeout > 0 {
+		_ = netConn.SetWriteDeadline(time.Now().Add(conf.HandshakeTimeout))
+	}
+	if _, err := io.WriteString(netConn, b.String()); err != nil {
+		netConn.Close()
+		c.Error(err)
+		return nil, err
+	}
+	if conf.HandshakeTimeout > 0 {
+		_ = netConn.SetWriteDeadline(time.Time{})
+	}
+
+	return newConn(netConn, brw.Reader, subprotocol, conf), nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header[textproto.CanonicalMIMEHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, protocol := range supported {
+		if headerContainsToken(r.Header, "Sec-Websocket-Protocol", protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// Conn 为服务端 WebSocket 连接
+// 同一时间只能有一个 goroutine 读取, 写入方法可以并发调用
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+
+	readLimit   int64
+	readErr     error
+	pingHandler func(appData string) error
+	pongHandler func(appData string) error
+
+	writeMu   sync.Mutex
+	bw        *bufio.Writer
+	writeSize int
+	closeSent bool
+}
+
+func newConn(netConn net.Conn, br *bufio.Reader, subprotocol string, conf WebSocketConfig) *Conn {
+	c := &Conn{
+		conn:        netConn,
+		br:          br,
+		subprotocol: subprotocol,
+		readLimit:   conf.ReadLimit,
+		writeSize:   conf.WriteBufferSize,
+	}
+	if c.readLimit <= 0 {
+		c.readLimit = defaultWebSocketLimit
+	}
+	if c.writeSize <= 0 {
+		c.writeSize = defaultWebSocketBufSize
+	}
+	if c.br == nil {
+		c.br = bufio.NewReader(netConn)
+	}
+	c.bw = bufio.NewWriterSize(netConn, c.writeSize+14)
+	c.SetPingHandler(nil)
+	c.SetPongHandler(nil)
+	return c
+}
+
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 设置单条消息的最大字节数, 超过时发送 1009 关闭帧并返回 ErrReadLimit
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPingHandler 设置收到 ping 时的处理函数, 为 nil 时回复相同内容的 pong
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(appData string) error {
+			err := c.WriteMessage(PongMessage, []byte(appData))
+			if err == ErrCloseSent {
+				return nil
+			}
+			return err
+		}
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler 设置收到 pong 时的处理函数, 常用于延长读超时
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pongHandler = h
+}
+
+// Close 直接关闭底层连接, 不发送关闭帧
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage 读取下一条完整消息, 分片会被合并, 控制帧在读取过程中自动处理
+// 收到关闭帧时回复关闭帧并返回 *CloseError, 之后的调用返回相同的错误
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	for {
+		fin, opcode, payload, err := c.readFrame(int64(len(p)))
+		if err != nil {
+			c.readErr = err
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			err = c.pingHandler(string(payload))
+		case PongMessage:
+			err = c.pongHandler(string(payload))
+		case CloseMessage:
+			err = c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				err = c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				err = c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			err = c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if err != nil {
+			c.readErr = err
+			return 0, nil, err
+		}
+		if opcode >= CloseMessage {
+			continue
+		}
+
+		p = append(p, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(p) {
+				c.readErr = c.fail(CloseInvalidFramePayloadData, "invalid utf-8 in text message")
+				return 0, nil, c.readErr
+			}
+			return messageType, p, nil
+		}
+	}
+}
+
+// readFrame 读取一帧并去除掩码, messageLen 为当前消息已读取的长度
+func (c *Conn) readFrame(messageLen int64) (fin bool, opcode int, payload []byte, err error) {
+	var hdr [8]byte
+	if _, err = io.ReadFull(c.br, hdr[:2]); err != nil {
+		return
+	}
+	fin = hdr[0]&finalBit != 0
+	opcode = int(hdr[0] & 0x0f)
+	if hdr[0]&rsvBits != 0 {
+		return fin, opcode, nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+	}
+	if hdr[1]&maskBit == 0 {
+		return fin, opcode, nil, c.fail(CloseProtocolError, "client frame is not masked")
+	}
+
+	length := int64(hdr[1] &^ maskBit)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.br, hdr[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(hdr[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, hdr[:8]); err != nil {
+			return
+		}
+		if hdr[0]&0x80 != 0 {
+			return fin, opcode, nil, c.fail(CloseProtocolError, "invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(hdr[:8]))
+	}
+
+	if opcode >= CloseMessage {
+		if !fin {
+			return fin, opcode, nil, c.fail(CloseProtocolError, "fragmented control frame")
+		}
+		if length > maxControlPayload {
+			return fin, opcode, nil, c.fail(CloseProtocolError, "control frame too large")
+		}
+	} else if messageLen+length > c.readLimit {
+		_ = c.fail(CloseMessageTooBig, "message too big")
+		return fin, opcode, nil, ErrReadLimit
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i&3]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *Conn) handleClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		code, text = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, fmt.Sprintf("invalid close code %d", code))
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid utf-8 in close reason")
+		}
+	}
+	if err := c.WriteMessage(CloseMessage, FormatCloseMessage(code, "")); err != nil && err != ErrCloseSent {
+		return err
+	}
+	return &CloseError{Code: code, Text: text}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail 发送关闭帧通知对端协议错误, 返回描述错误的 error
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.WriteMessage(CloseMessage, FormatCloseMessage(code, reason))
+	return errors.New("websocket: " + reason)
+}
+
+// WriteMessage 以单帧写出一条消息, 控制消息的内容不能超过 125 字节
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return errors.New("websocket: control frame too large")
+		}
+	default:
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(true, messageType, data)
+}
+
+// NextWriter 返回写入下一条消息的 Writer, 内容按 WriteBufferSize 分片发送
+// Close 之前会阻塞其他写入, 包括自动回复的 pong
+func (c *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	c.writeMu.Lock()
+	if c.closeSent {
+		c.writeMu.Unlock()
+		return nil, ErrCloseSent
+	}
+	return &messageWriter{c: c, opcode: messageType, buf: make([]byte, 0, c.writeSize)}, nil
+}
+
+// writeFrame 写出一帧, 调用者需持有 writeMu
+func (c *Conn) writeFrame(fin bool, opcode int, data []byte) error {
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	var hdr [10]byte
+	hdr[0] = byte(opcode)
+	if fin {
+		hdr[0] |= finalBit
+	}
+	n := 2
+	switch length := len(data); {
+	case length <= maxControlPayload:
+		hdr[1] = byte(length)
+	case length <= 0xFFFF:
+		hdr[1] = 126
+		binary.BigEndian.PutUint16(hdr[2:], uint16(length))
+		n += 2
+	default:
+		hdr[1] = 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(length))
+		n += 8
+	}
+	if _, err := c.bw.Write(hdr[:n]); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(data); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+type messageWriter struct {
+	c      *Conn
+	opcode int
+	buf    []byte
+	closed bool
+}
+
+func (w *messageWriter) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if err = w.flushFrame(false); err != nil {
+				return
+			}
+		}
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return
+}
+
+func (w *messageWriter) flushFrame(fin bool) error {
+	err := w.c.writeFrame(fin, w.opcode, w.buf)
+	w.opcode = continuationFrame
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.c.writeMu.Unlock()
+	return w.flushFrame(true)
+}
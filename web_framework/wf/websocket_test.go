@@ -0,0 +1,240 @@
+package wf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string, header http.Header) *wsTestClient {
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, err := http.NewRequest("GET", srv.URL+path, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	require.NoError(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	return &wsTestClient{t: t, conn: conn, br: br, resp: resp}
+}
+
+func (c *wsTestClient) write(fin bool, opcode int, payload []byte, masked bool) {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finalBit
+	}
+	frame := []byte{b0, 0}
+	switch {
+	case len(payload) <= 125:
+		frame[1] = byte(len(payload))
+	default:
+		frame[1] = 126
+		frame = append(frame, byte(len(payload)>>8), byte(len(payload)))
+	}
+	data := append([]byte(nil), payload...)
+	if masked {
+		frame[1] |= maskBit
+		mask := [4]byte{1, 2, 3, 4}
+		frame = append(frame, mask[:]...)
+		for i := range data {
+			data[i] ^= mask[i&3]
+		}
+	}
+	_, err := c.conn.Write(append(frame, data...))
+	require.NoError(c.t, err)
+}
+
+func (c *wsTestClient) read() (fin bool, opcode int, payload []byte) {
+	var hdr [2]byte
+	_, err := io.ReadFull(c.br, hdr[:])
+	require.NoError(c.t, err)
+	length := int(hdr[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(c.t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	require.NoError(c.t, err)
+	return hdr[0]&finalBit != 0, int(hdr[0] & 0x0f), payload
+}
+
+func (c *wsTestClient) readClose() int {
+	_, opcode, payload := c.read()
+	require.Equal(c.t, CloseMessage, opcode)
+	require.GreaterOrEqual(c.t, len(payload), 2)
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	r := New()
+	closed := make(chan error, 1)
+	ws := r.Group("/ws")
+	ws.Use(func(c *Context) { c.Set("user", "geek") })
+	ws.GET("/echo", WebSocketWithConfig(WebSocketConfig{Subprotocols: []string{"chat", "json"}}, func(c *Context, conn *Conn) {
+		require.Equal(t, "geek", c.GetString("user"))
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			if err = conn.WriteMessage(messageType, p); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := dialWebSocket(t, srv, "/ws/echo", http.Header{"Sec-Websocket-Protocol": {"json, chat"}})
+	require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", client.resp.Header.Get("Sec-WebSocket-Accept"))
+	require.Equal(t, "chat", client.resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	client.write(true, TextMessage, []byte("hello"), true)
+	fin, opcode, payload := client.read()
+	require.True(t, fin)
+	require.Equal(t, TextMessage, opcode)
+	require.Equal(t, "hello", string(payload))
+
+	// 分片中间插入 ping, 先收到 pong 再收到合并后的消息
+	client.write(false, BinaryMessage, []byte("frag"), true)
+	client.write(true, PingMessage, []byte("p"), true)
+	client.write(false, continuationFrame, []byte("men"), true)
+	client.write(true, continuationFrame, []byte("ted"), true)
+	_, opcode, payload = client.read()
+	require.Equal(t, PongMessage, opcode)
+	require.Equal(t, "p", string(payload))
+	_, opcode, payload = client.read()
+	require.Equal(t, BinaryMessage, opcode)
+	require.Equal(t, "fragmented", string(payload))
+
+	big := strings.Repeat("x", 300)
+	client.write(true, TextMessage, []byte(big), true)
+	_, _, payload = client.read()
+	require.Equal(t, big, string(payload))
+
+	client.write(true, CloseMessage, FormatCloseMessage(CloseGoingAway, "bye"), true)
+	require.Equal(t, CloseGoingAway, client.readClose())
+	err := <-closed
+	require.True(t, IsCloseError(err, CloseGoingAway))
+	require.Equal(t, "bye", err.(*CloseError).Text)
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	r := New()
+	errs := make(chan error, 1)
+	handler := func(c *Context, conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		errs <- err
+	}
+	r.GET("/ws", WebSocket(handler))
+	r.GET("/small", WebSocketWithConfig(WebSocketConfig{ReadLimit: 4}, handler))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := dialWebSocket(t, srv, "/ws", nil)
+	client.write(true, TextMessage, []byte("hi"), false)
+	require.Equal(t, CloseProtocolError, client.readClose())
+	require.Error(t, <-errs)
+
+	client = dialWebSocket(t, srv, "/small", nil)
+	client.write(false, TextMessage, []byte("abc"), true)
+	client.write(true, continuationFrame, []byte("de"), true)
+	require.Equal(t, CloseMessageTooBig, client.readClose())
+	require.Equal(t, ErrReadLimit, <-errs)
+
+	client = dialWebSocket(t, srv, "/ws", nil)
+	client.write(true, TextMessage, []byte{0xff, 0xfe}, true)
+	require.Equal(t, CloseInvalidFramePayloadData, client.readClose())
+	require.Error(t, <-errs)
+
+	client = dialWebSocket(t, srv, "/ws", nil)
+	client.write(false, PingMessage, nil, true)
+	require.Equal(t, CloseProtocolError, client.readClose())
+	require.Error(t, <-errs)
+}
+
+func TestWebSocketNextWriter(t *testing.T) {
+	r := New()
+	r.GET("/ws", WebSocketWithConfig(WebSocketConfig{WriteBufferSize: 4}, func(c *Context, conn *Conn) {
+		w, err := conn.NextWriter(TextMessage)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, "abcdefghij")
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := dialWebSocket(t, srv, "/ws", nil)
+	var frames []string
+	for {
+		fin, opcode, payload := client.read()
+		if len(frames) == 0 {
+			require.Equal(t, TextMessage, opcode)
+		} else {
+			require.Equal(t, continuationFrame, opcode)
+		}
+		frames = append(frames, string(payload))
+		if fin {
+			break
+		}
+	}
+	require.Equal(t, []string{"abcd", "efgh", "ij"}, frames)
+	require.Equal(t, CloseNormalClosure, client.readClose())
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	r := New()
+	r.GET("/ws", WebSocket(func(c *Context, conn *Conn) {}))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	w := performRequest(r, "GET", "/ws")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	client := dialWebSocket(t, srv, "/ws", http.Header{"Sec-Websocket-Version": {"8"}})
+	require.Equal(t, http.StatusUpgradeRequired, client.resp.StatusCode)
+	require.Equal(t, "13", client.resp.Header.Get("Sec-WebSocket-Version"))
+
+	client = dialWebSocket(t, srv, "/ws", http.Header{"Origin": {"http://evil.example"}})
+	require.Equal(t, http.StatusForbidden, client.resp.StatusCode)
+
+	client = dialWebSocket(t, srv, "/ws", http.Header{"Sec-Websocket-Key": {"short"}})
+	require.Equal(t, http.StatusBadRequest, client.resp.StatusCode)
+
+	client = dialWebSocket(t, srv, "/ws", http.Header{"Origin": {srv.URL}})
+	require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	require.Equal(t, CloseNormalClosure, client.readClose())
+}
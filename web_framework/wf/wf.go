@@ -1,9 +1,13 @@
 package wf
 
 import (
+	"net"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 )
 
 type HandlerFunc func(*Context)
@@ -12,9 +16,34 @@ type HandlersChain []HandlerFunc
 
 type Engine struct {
 	RouterGroup
+	// RemoteIPHeaders 为 ClientIP 依次查找的请求头, 只有请求来自可信代理时才使用
+	RemoteIPHeaders []string
+	// ShutdownTimeout 为 RunContext 收到退出信号后等待请求完成的最长时间
+	ShutdownTimeout time.Duration
+	// SecureJSONPrefix 为 SecureJSON 在数组前添加的前缀, 默认为 "while(1);"
+	SecureJSONPrefix string
+	// MaxMultipartMemory 为解析 multipart 表单时内存中保存的最大字节数, 超出部分写入临时文件
+	MaxMultipartMemory int64
+
 	roots         map[string]*node //method to root
+	maxParams     int
+	pool          sync.Pool
+	validator     *Validator
+	noRoute       HandlersChain
+	noMethod      HandlersChain
+	allNoRoute    HandlersChain
+	allNoMethod   HandlersChain
 	htmlTemplates *template.Template
 	funcMap       template.FuncMap
+	trustedCIDRs  []*net.IPNet
+	namedRoutes   map[string]string //name to path
+	renders       map[string]RenderFunc
+
+	serverMu      sync.Mutex
+	server        *http.Server
+	onShutdown    []func()
+	hijackedMu    sync.Mutex
+	hijackedConns map[*hijackedConn]struct{}
 }
 
 func New() *Engine {
@@ -23,117 +52,233 @@ func New() *Engine {
 			prefix:   "/",
 			handlers: nil,
 		},
-		roots: make(map[string]*node),
+		RemoteIPHeaders:    []string{"X-Forwarded-For", "X-Real-IP"},
+		ShutdownTimeout:    10 * time.Second,
+		SecureJSONPrefix:   "while(1);",
+		MaxMultipartMemory: defaultMultipartMemory,
+		roots:              make(map[string]*node),
+		validator:          NewValidator(),
+		renders:            defaultRenders(),
 	}
 	engine.RouterGroup.engine = engine
+	engine.pool.New = func() interface{} {
+		return newContext(engine)
+	}
+	engine.rebuildNotFoundHandlers()
 	return engine
 }
 
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	c := newContext(w, r, engine)
+	c := engine.pool.Get().(*Context)
+	c.reset(w, r)
 	engine.handle(c)
+	engine.pool.Put(c)
+}
+
+// SetTrustedProxies 设置可信代理的 IP 或 CIDR, 默认不信任任何代理
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return &net.ParseError{Type: "IP address", Text: proxy}
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
 }
 
-func (engine *Engine) Run(address string) error {
-	return http.ListenAndServe(address, engine)
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range engine.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIPFromHeader 从右向左跳过可信代理, 返回第一个不可信的地址
+func (engine *Engine) clientIPFromHeader(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ipStr := strings.TrimSpace(items[i])
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return "", false
+		}
+		if i == 0 || !engine.isTrustedProxy(ip) {
+			return ipStr, true
+		}
+	}
+	return "", false
 }
 
 func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
 	engine.funcMap = funcMap
 }
 
+// LoadHTMLGlob 加载模板, 模板中可以使用 url 函数按路由名称生成路径
 func (engine *Engine) LoadHTMLGlob(pattern string) {
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+	funcMap := template.FuncMap{"url": engine.URL}
+	for name, fn := range engine.funcMap {
+		funcMap[name] = fn
+	}
+	engine.htmlTemplates = template.Must(template.New("").Funcs(funcMap).ParseGlob(pattern))
+}
+
+// RegisterValidation 注册自定义校验规则, 可在 binding tag 中使用
+func (engine *Engine) RegisterValidation(name string, fn ValidatorFunc) {
+	engine.validator.RegisterValidation(name, fn)
 }
 
 func (engine *Engine) Use(middlewares ...HandlerFunc) *Engine {
 	engine.RouterGroup.Use(middlewares...)
+	engine.rebuildNotFoundHandlers()
 	return engine
 }
 
-func (engine *Engine) addRoute(method string, path string, handlers HandlersChain) {
-	parts := parsePath(path)
-	root, ok := engine.roots[method]
-	if !ok {
-		engine.roots[method] = &node{}
-		root = engine.roots[method]
+// NoRoute 设置没有匹配路由时的处理函数, 在全局中间件之后执行
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+	engine.rebuildNotFoundHandlers()
+}
+
+// NoMethod 设置路径匹配但方法不匹配时的处理函数, 在全局中间件之后执行
+// 执行前 Allow 头已经设置
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
+	engine.rebuildNotFoundHandlers()
+}
+
+func (engine *Engine) rebuildNotFoundHandlers() {
+	noRoute, noMethod := engine.noRoute, engine.noMethod
+	if len(noRoute) == 0 {
+		noRoute = HandlersChain{defaultNoRoute}
 	}
-	n := root.search(parts, 0)
-	if n != nil {
-		//匹配 判断优先级是否重复
-		// eg:?
-		// /h1/:name -> /h1/name yes
-		// /h1/:name -> /h1/*path yes
-		// /h1/:name -> /h1/:id no
-		// /h1/:name1/name2 -> /h1/name2/:name2 no? TODO
-		parts := parsePath(path)       //插入
-		pathParts := parsePath(n.path) //已有
-		if len(pathParts) != len(parts) {
-			goto insert
+	if len(noMethod) == 0 {
+		noMethod = HandlersChain{defaultNoMethod}
+	}
+	engine.allNoRoute = combineHandlers(engine.handlers, noRoute)
+	engine.allNoMethod = combineHandlers(engine.handlers, noMethod)
+}
+
+func defaultNoRoute(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
+
+func defaultNoMethod(c *Context) {
+	c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s\n", c.Path)
+}
+
+func (engine *Engine) addRoute(method string, path string, handlers HandlersChain) *node {
+	parts := parsePath(path)
+	segments := parseSegments(parts)
+	wilds := 0
+	for _, seg := range segments {
+		if seg.nType == static {
+			continue
 		}
-		//长度相同
-		for index, part := range parts {
-			comPart := pathParts[index]
-			if part == comPart {
-				continue
-			} else if comPart[0] != part[0] {
-				goto insert
-			} else {
-				panic("Duplicate routing")
-			}
+		wilds++
+		if _, constraint := splitConstraint(seg.text); constraint != "" {
+			compileConstraint(constraint) //插入前检查约束, 避免插入一半后 panic
 		}
-		panic("Duplicate routing")
 	}
-insert:
-	engine.roots[method].insert(path, parts, handlers, 0)
-}
 
-func (engine *Engine) getRoute(method string, path string) (n *node, params map[string]string) {
 	root, ok := engine.roots[method]
 	if !ok {
-		return nil, nil
+		root = &node{}
+		engine.roots[method] = root
+	}
+	if root.conflict(segments) {
+		panic("Duplicate routing")
 	}
+	n := root.insert("/"+strings.Join(parts, "/"), segments, handlers)
 
-	searchParts := parsePath(path)
-	n = root.search(searchParts, 0)
+	if wilds > engine.maxParams {
+		engine.maxParams = wilds
+	}
+	return n
+}
+
+func (engine *Engine) getRoute(method string, path string) (*node, Params) {
+	params := make(Params, 0, engine.maxParams)
+	n := engine.findRoute(method, path, &params)
 	if n == nil {
 		return nil, nil
 	}
+	return n, params
+}
 
-	params = make(map[string]string)
-	parts := parsePath(n.path)
-	for index, part := range parts {
-		if part[0] == ':' {
-			params[part[1:]] = searchParts[index]
-		} else if part[0] == '*' {
-			params[part[1:]] = strings.Join(searchParts[index:], "/")
-			break
-		}
+// findRoute 查找路由, 参数写入 params 复用的底层数组
+func (engine *Engine) findRoute(method string, path string, params *Params) *node {
+	root, ok := engine.roots[method]
+	if !ok {
+		return nil
 	}
-	return n, params
+	return root.search(cleanPath(path), params)
 }
 
 func (engine *Engine) handle(c *Context) {
-	n, params := engine.getRoute(c.Method, c.Path)
+	n := engine.findRoute(c.Method, c.Path, &c.Params)
+	if n == nil && c.Method == http.MethodHead {
+		//HEAD 未注册时回退到 GET
+		n = engine.findRoute(http.MethodGet, c.Path, &c.Params)
+	}
 	if n != nil {
-		c.Params = params
 		c.handlers = n.handlers
+	} else if allow := engine.allowedMethods(c.Path); len(allow) != 0 {
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		c.Writer.WriteHeader(http.StatusMethodNotAllowed)
+		c.handlers = engine.allNoMethod
 	} else {
-		c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+		c.Writer.WriteHeader(http.StatusNotFound)
+		c.handlers = engine.allNoRoute
 	}
 	c.Next()
+	c.Writer.WriteHeaderNow()
+}
+
+// allowedMethods 返回能匹配 path 的所有方法, 用于 405 的 Allow 头
+func (engine *Engine) allowedMethods(path string) []string {
+	allow := make([]string, 0, len(engine.roots)+1)
+	hasGet, hasHead := false, false
+	for method := range engine.roots {
+		if n, _ := engine.getRoute(method, path); n != nil {
+			allow = append(allow, method)
+			hasGet = hasGet || method == http.MethodGet
+			hasHead = hasHead || method == http.MethodHead
+		}
+	}
+	if hasGet && !hasHead {
+		allow = append(allow, http.MethodHead)
+	}
+	sort.Strings(allow)
+	return allow
 }
 
 func parsePath(path string) []string {
-	vs := strings.Split(path, "/")
-
-	parts := make([]string, 0)
-	for _, item := range vs {
-		if item != "" {
-			parts = append(parts, item)
-			if item[0] == '*' {
-				break
-			}
+	parts := splitPath(path)
+	for i, item := range parts {
+		if item[0] == '*' {
+			return parts[:i+1]
 		}
 	}
 	return parts